@@ -0,0 +1,90 @@
+package clidisplay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+// Evaluation is an expression given on the command line, and its value.
+type Evaluation struct {
+	Expression  string
+	Value       cty.Value
+	Diagnostics hcl.Diagnostics
+}
+
+// Evaluations writes each expression with its value and type, like
+// 'local.image = "ubuntu" (string)'.
+func Evaluations(writer io.Writer, evals []Evaluation) {
+	for _, eval := range evals {
+		if eval.Value == cty.NilVal {
+			_, _ = fmt.Fprintf(writer, "%s = (error)\n", eval.Expression)
+			continue
+		}
+		_, _ = fmt.Fprintf(writer, "%s = %s (%s)\n", eval.Expression, formatValue(eval.Value), eval.Value.Type().FriendlyName())
+		for _, mark := range coderism.UnknownMarks(eval.Value) {
+			_, _ = fmt.Fprintf(writer, "  %s\n", mark.String())
+		}
+	}
+}
+
+// formatValue formats a value like it is written in HCL.
+func formatValue(val cty.Value) string {
+	val, _ = val.UnmarkDeep()
+	if !val.IsWhollyKnown() {
+		return "(not known)"
+	}
+	return strings.TrimSpace(string(hclwrite.TokensForValue(val).Bytes()))
+}
+
+type JSONEvaluation struct {
+	Expression string          `json:"expression"`
+	Type       json.RawMessage `json:"type,omitempty"`
+	// Value is the JSON encoding of the value. It is null if the value is
+	// not known.
+	Value          json.RawMessage     `json:"value"`
+	Known          bool                `json:"known"`
+	UnknownReasons []JSONUnknownReason `json:"unknown_reasons,omitempty"`
+	Diagnostics    []JSONDiagnostic    `json:"diagnostics"`
+}
+
+// EvaluationsJSON writes the evaluations as a command document. The diags
+// are of the template, the diagnostics of an expression are in its
+// evaluation.
+func EvaluationsJSON(writer io.Writer, evals []Evaluation, diags hcl.Diagnostics) error {
+	out := make([]JSONEvaluation, 0, len(evals))
+	for _, eval := range evals {
+		je := JSONEvaluation{
+			Expression:  eval.Expression,
+			Value:       json.RawMessage("null"),
+			Diagnostics: jsonDiagnostics(eval.Diagnostics),
+		}
+		if eval.Value != cty.NilVal {
+			val, _ := eval.Value.UnmarkDeep()
+			ty, err := ctyjson.MarshalType(val.Type())
+			if err != nil {
+				return err
+			}
+			je.Type = ty
+			je.UnknownReasons = jsonUnknownReasons(coderism.UnknownMarks(eval.Value))
+			if val.IsWhollyKnown() {
+				data, err := ctyjson.Marshal(val, val.Type())
+				if err != nil {
+					return err
+				}
+				je.Value, je.Known = data, true
+			}
+		}
+		out = append(out, je)
+	}
+
+	return CommandJSON(writer, "eval", out, diags)
+}
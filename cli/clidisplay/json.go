@@ -0,0 +1,271 @@
+package clidisplay
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/hashicorp/hcl/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+// JSONOutputVersion is bumped whenever a field in the JSON document is
+// removed or changes meaning. Adding fields does not bump the version.
+const JSONOutputVersion = 1
+
+type JSONOutput struct {
+	Version       int              `json:"version"`
+	Parameters    []JSONParameter  `json:"parameters"`
+	WorkspaceTags []JSONTagBlock   `json:"workspace_tags"`
+	Resources     []JSONResource   `json:"resources"`
+	Diagnostics   []JSONDiagnostic `json:"diagnostics"`
+}
+
+// JSONCommandOutput is the JSON document of a subcommand. It has the same
+// version as JSONOutput.
+type JSONCommandOutput struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	// Result is the output of the subcommand.
+	Result      interface{}      `json:"result"`
+	Diagnostics []JSONDiagnostic `json:"diagnostics"`
+}
+
+type JSONParameter struct {
+	// Data is the protojson encoding of the proto.RichParameter, with every
+	// field emitted, even if it is the zero value.
+	Data  json.RawMessage `json:"data"`
+	Value JSONValue       `json:"value"`
+	// Module is the address of the module that declares the parameter, or
+	// empty for the root module.
+	Module string `json:"module"`
+}
+
+type JSONValue struct {
+	Value string `json:"value"`
+	Known bool   `json:"known"`
+	Null  bool   `json:"null"`
+	// UnknownReasons are why the value is not known.
+	UnknownReasons []JSONUnknownReason `json:"unknown_reasons,omitempty"`
+}
+
+type JSONUnknownReason struct {
+	Reason    string `json:"reason"`
+	Reference string `json:"reference,omitempty"`
+}
+
+type JSONTagBlock struct {
+	Tags []JSONTag `json:"tags"`
+	// Module is the address of the module that declares the block, or empty
+	// for the root module.
+	Module string `json:"module"`
+}
+
+type JSONTag struct {
+	Key        string   `json:"key"`
+	Value      string   `json:"value"`
+	Known      bool     `json:"known"`
+	References []string `json:"references"`
+	// Unknown is the chain of references to the value that is not known,
+	// for tags that are not known.
+	Unknown        []JSONUnknownStep   `json:"unknown,omitempty"`
+	UnknownReasons []JSONUnknownReason `json:"unknown_reasons,omitempty"`
+}
+
+type JSONUnknownStep struct {
+	Reference string     `json:"reference"`
+	Range     *JSONRange `json:"range"`
+	Reason    string     `json:"reason,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+}
+
+type JSONResource struct {
+	Address  string `json:"address"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	// Expansion is "count", "for_each", or empty for a single instance.
+	Expansion  string     `json:"expansion"`
+	CountKnown bool       `json:"count_known"`
+	Instances  []string   `json:"instances"`
+	Range      *JSONRange `json:"range"`
+}
+
+type JSONDiagnostic struct {
+	Severity string     `json:"severity"`
+	Summary  string     `json:"summary"`
+	Detail   string     `json:"detail"`
+	Subject  *JSONRange `json:"subject,omitempty"`
+	Context  *JSONRange `json:"context,omitempty"`
+}
+
+type JSONRange struct {
+	Filename string  `json:"filename"`
+	Start    JSONPos `json:"start"`
+	End      JSONPos `json:"end"`
+}
+
+type JSONPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// JSON writes the extracted output as a single versioned JSON document.
+// Diagnostics from evaluating the workspace tags are included alongside
+// the diagnostics passed in.
+func JSON(writer io.Writer, output coderism.Output, diags hcl.Diagnostics) error {
+	doc := JSONOutput{
+		Version:       JSONOutputVersion,
+		Parameters:    make([]JSONParameter, 0, len(output.Parameters)),
+		WorkspaceTags: make([]JSONTagBlock, 0, len(output.WorkspaceTags)),
+		Resources:     make([]JSONResource, 0, len(output.Resources)),
+	}
+
+	marshaller := protojson.MarshalOptions{
+		UseProtoNames:   true,
+		EmitUnpopulated: true,
+	}
+	for _, p := range output.Parameters {
+		data, err := marshaller.Marshal(p.Data)
+		if err != nil {
+			return err
+		}
+
+		doc.Parameters = append(doc.Parameters, JSONParameter{
+			Data:   data,
+			Value:  jsonParameterValue(p),
+			Module: p.ModuleAddress,
+		})
+	}
+
+	for _, tb := range output.WorkspaceTags {
+		block := JSONTagBlock{
+			Tags:   make([]JSONTag, 0, len(tb.Tags)),
+			Module: tb.ModuleAddress,
+		}
+		for _, tag := range tb.Tags {
+			jt := JSONTag{
+				Key:        tag.SafeKeyString(),
+				References: tag.References(),
+			}
+			if tag.IsKnown() {
+				k, v, tDiags := tag.EvalToString(tb)
+				diags = diags.Extend(tDiags)
+				if !tDiags.HasErrors() {
+					jt.Key, jt.Value, jt.Known = k, v, true
+				}
+			}
+			for _, step := range tb.ExplainUnknown(tag) {
+				step := step
+				jt.Unknown = append(jt.Unknown, JSONUnknownStep{
+					Reference: step.Reference,
+					Range:     jsonRange(&step.Range),
+					Reason:    string(step.Reason),
+					Detail:    step.Detail,
+				})
+			}
+			jt.UnknownReasons = jsonUnknownReasons(tag.UnknownReasons())
+			block.Tags = append(block.Tags, jt)
+		}
+		doc.WorkspaceTags = append(doc.WorkspaceTags, block)
+	}
+
+	for _, r := range output.Resources {
+		r := r
+		instances := r.Instances
+		if instances == nil {
+			instances = []string{}
+		}
+		doc.Resources = append(doc.Resources, JSONResource{
+			Address:    r.Address,
+			Type:       r.Type,
+			Name:       r.Name,
+			Provider:   r.Provider,
+			Expansion:  r.Expansion,
+			CountKnown: r.CountKnown,
+			Instances:  instances,
+			Range:      jsonRange(&r.Range),
+		})
+	}
+
+	doc.Diagnostics = jsonDiagnostics(diags)
+
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// CommandJSON writes the result of the subcommand as a versioned JSON
+// document, with the diagnostics of evaluating the template.
+func CommandJSON(writer io.Writer, command string, result interface{}, diags hcl.Diagnostics) error {
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	return enc.Encode(JSONCommandOutput{
+		Version:     JSONOutputVersion,
+		Command:     command,
+		Result:      result,
+		Diagnostics: jsonDiagnostics(diags),
+	})
+}
+
+func jsonParameterValue(p coderism.Parameter) JSONValue {
+	val := p.Value.Value
+	switch {
+	case !val.IsKnown():
+		return JSONValue{UnknownReasons: jsonUnknownReasons(p.Value.UnknownReasons())}
+	case val.IsNull():
+		return JSONValue{Known: true, Null: true}
+	}
+
+	str, err := p.ValueAsString()
+	if err != nil {
+		return JSONValue{}
+	}
+	return JSONValue{Value: str, Known: true}
+}
+
+func jsonUnknownReasons(marks []coderism.UnknownMark) []JSONUnknownReason {
+	if len(marks) == 0 {
+		return nil
+	}
+	out := make([]JSONUnknownReason, 0, len(marks))
+	for _, mark := range marks {
+		out = append(out, JSONUnknownReason{
+			Reason:    string(mark.Reason),
+			Reference: mark.Reference,
+		})
+	}
+	return out
+}
+
+func jsonDiagnostics(diags hcl.Diagnostics) []JSONDiagnostic {
+	out := make([]JSONDiagnostic, 0, len(diags))
+	for _, diag := range diags {
+		severity := "error"
+		if diag.Severity == hcl.DiagWarning {
+			severity = "warning"
+		}
+
+		out = append(out, JSONDiagnostic{
+			Severity: severity,
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+			Subject:  jsonRange(diag.Subject),
+			Context:  jsonRange(diag.Context),
+		})
+	}
+	return out
+}
+
+func jsonRange(r *hcl.Range) *JSONRange {
+	if r == nil {
+		return nil
+	}
+	return &JSONRange{
+		Filename: r.Filename,
+		Start:    JSONPos{Line: r.Start.Line, Column: r.Start.Column, Byte: r.Start.Byte},
+		End:      JSONPos{Line: r.End.Line, Column: r.End.Column, Byte: r.End.Byte},
+	}
+}
@@ -0,0 +1,30 @@
+package clidisplay
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/coder/terraform-eval/engine"
+)
+
+func PlanComparison(writer io.Writer, comparison engine.PlanComparison) {
+	tableWriter := table.NewWriter()
+	tableWriter.SetTitle("Preview vs Plan")
+	tableWriter.SetStyle(table.StyleLight)
+	tableWriter.Style().Options.SeparateColumns = false
+	row := table.Row{"Kind", "Name", "Status", "Preview", "Planned"}
+	tableWriter.AppendHeader(row)
+	for _, v := range comparison.Values {
+		preview := v.Preview
+		switch v.Status {
+		case engine.PlanValueUnknown:
+			preview = "??"
+		case engine.PlanValueInvalid:
+			preview = v.Error
+		}
+		tableWriter.AppendRow(table.Row{v.Kind, v.Name, v.Status, preview, v.Planned})
+	}
+	_, _ = fmt.Fprintln(writer, tableWriter.Render())
+}
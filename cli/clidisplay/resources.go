@@ -3,10 +3,12 @@ package clidisplay
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 
+	"github.com/coder/terraform-eval/engine"
 	"github.com/coder/terraform-eval/engine/coderism"
 	"github.com/coder/terraform-eval/engine/coderism/proto"
 
@@ -20,7 +22,24 @@ func WorkspaceTags(writer io.Writer, tags coderism.TagBlocks) hcl.Diagnostics {
 	tableWriter.SetTitle("Provisioner Tags")
 	tableWriter.SetStyle(table.StyleLight)
 	tableWriter.Style().Options.SeparateColumns = false
+	// Templates without tags in modules keep the short table.
+	withModules := false
+	for _, tb := range tags {
+		if tb.ModuleAddress != "" {
+			withModules = true
+		}
+	}
+	appendRow := func(tb coderism.TagBlock, row table.Row) {
+		if withModules {
+			row = append(row, tb.ModuleAddress)
+		}
+		tableWriter.AppendRow(row)
+	}
+
 	row := table.Row{"Key", "Value", "Refs"}
+	if withModules {
+		row = append(row, "Module")
+	}
 	tableWriter.AppendHeader(row)
 	for _, tb := range tags {
 		for _, tag := range tb.Tags {
@@ -28,14 +47,22 @@ func WorkspaceTags(writer io.Writer, tags coderism.TagBlocks) hcl.Diagnostics {
 				k, v, tDiags := tag.EvalToString(tb)
 				diags = diags.Extend(tDiags)
 				if !diags.HasErrors() {
-					tableWriter.AppendRow(table.Row{k, v, ""})
+					appendRow(tb, table.Row{k, v, ""})
 					continue
 				}
 			}
 
 			k := tag.SafeKeyString()
 			refs := tag.References()
-			tableWriter.AppendRow(table.Row{k, "??", strings.Join(refs, "\n")})
+			if steps := tb.ExplainUnknown(tag); len(steps) > 0 {
+				// Show the chain to the value that is not known, rather
+				// than every reference.
+				refs = make([]string, 0, len(steps))
+				for _, step := range steps {
+					refs = append(refs, step.String())
+				}
+			}
+			appendRow(tb, table.Row{k, "??", strings.Join(refs, "\n-> ")})
 
 			//refs := tb.AllReferences()
 			//refsStr := make([]string, 0, len(refs))
@@ -49,6 +76,31 @@ func WorkspaceTags(writer io.Writer, tags coderism.TagBlocks) hcl.Diagnostics {
 	return diags
 }
 
+func Resources(writer io.Writer, resources []coderism.Resource) {
+	tableWriter := table.NewWriter()
+	tableWriter.SetTitle("Resources")
+	tableWriter.SetStyle(table.StyleLight)
+	tableWriter.Style().Options.SeparateColumns = false
+	row := table.Row{"Address", "Provider", "Instances"}
+	tableWriter.AppendHeader(row)
+	for _, r := range resources {
+		tableWriter.AppendRow(table.Row{r.Address, r.Provider, formatInstances(r)})
+	}
+	_, _ = fmt.Fprintln(writer, tableWriter.Render())
+}
+
+func formatInstances(r coderism.Resource) string {
+	switch {
+	case r.Expansion == "":
+		return "1"
+	case !r.CountKnown:
+		return fmt.Sprintf("?? (%s)", r.Expansion)
+	case len(r.Instances) == 0:
+		return fmt.Sprintf("0 (%s)", r.Expansion)
+	}
+	return fmt.Sprintf("%d (%s): %s", len(r.Instances), r.Expansion, strings.Join(r.Instances, ", "))
+}
+
 func Parameters(writer io.Writer, params []coderism.Parameter) {
 	tableWriter := table.NewWriter()
 	//tableWriter.SetTitle("Parameters")
@@ -65,8 +117,12 @@ func Parameters(writer io.Writer, params []coderism.Parameter) {
 			v = "unknown"
 		}
 
+		name := p.Data.Name
+		if p.ModuleAddress != "" {
+			name = fmt.Sprintf("%s (%s)", name, p.ModuleAddress)
+		}
 		tableWriter.AppendRow(table.Row{
-			fmt.Sprintf("%s: %s\n%s", p.Data.Name, p.Data.Description, formatOptions(v, p.Data.Options)),
+			fmt.Sprintf("%s: %s\n%s", name, p.Data.Description, formatOptions(v, p.Data.Options)),
 		})
 		tableWriter.AppendSeparator()
 	}
@@ -103,3 +159,81 @@ func maxLength(s string, max int) string {
 	}
 	return s
 }
+
+func ProvisionerMatches(writer io.Writer, matches []coderism.ProvisionerMatch) {
+	tableWriter := table.NewWriter()
+	tableWriter.SetTitle("Provisioner Daemons")
+	tableWriter.SetStyle(table.StyleLight)
+	tableWriter.Style().Options.SeparateColumns = false
+	row := table.Row{"Daemon", "Status", "Reasons"}
+	tableWriter.AppendHeader(row)
+	for _, m := range matches {
+		reasons := make([]string, 0, len(m.Mismatches))
+		for _, mismatch := range m.Mismatches {
+			reasons = append(reasons, mismatch.String())
+		}
+		tableWriter.AppendRow(table.Row{m.Daemon.Name, m.Status, strings.Join(reasons, "\n")})
+	}
+	_, _ = fmt.Fprintln(writer, tableWriter.Render())
+}
+
+func TagSets(writer io.Writer, report engine.TagSetReport) {
+	tableWriter := table.NewWriter()
+	title := fmt.Sprintf("Workspace Tag Sets (%d of %d combinations)", report.Evaluated, report.Total)
+	if report.Sampled {
+		title += ", sampled"
+	}
+	tableWriter.SetTitle(title)
+	tableWriter.SetStyle(table.StyleLight)
+	tableWriter.Style().Options.SeparateColumns = false
+	row := table.Row{"Tags", "Combinations"}
+	tableWriter.AppendHeader(row)
+	for _, set := range report.TagSets {
+		tags := make([]string, 0, len(set.Tags)+len(set.Unknowns))
+		for k, v := range set.Tags {
+			tags = append(tags, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(tags)
+		for _, k := range set.Unknowns {
+			tags = append(tags, fmt.Sprintf("%s=??", k))
+		}
+
+		combinations := make([]string, 0, len(set.Combinations))
+		for _, combination := range set.Combinations {
+			values := make([]string, 0, len(report.Parameters))
+			for _, name := range report.Parameters {
+				values = append(values, fmt.Sprintf("%s=%s", name, combination[name]))
+			}
+			combinations = append(combinations, strings.Join(values, " "))
+		}
+		tableWriter.AppendRow(table.Row{strings.Join(tags, "\n"), strings.Join(combinations, "\n")})
+		tableWriter.AppendSeparator()
+	}
+	_, _ = fmt.Fprintln(writer, tableWriter.Render())
+}
+
+func Transitions(writer io.Writer, report engine.TransitionReport) {
+	tableWriter := table.NewWriter()
+	tableWriter.SetTitle("Workspace Transitions")
+	tableWriter.SetStyle(table.StyleLight)
+	tableWriter.Style().Options.SeparateColumns = false
+	row := table.Row{"", "Kind", "Address"}
+	for _, transition := range report.Transitions {
+		row = append(row, transition)
+	}
+	tableWriter.AppendHeader(row)
+	for _, change := range report.Changes {
+		// Mark what changes between transitions, so it stands out from
+		// what every transition has.
+		changed := ""
+		if change.Changed {
+			changed = "*"
+		}
+		row := table.Row{changed, change.Kind, change.Address}
+		for _, transition := range report.Transitions {
+			row = append(row, change.Values[transition])
+		}
+		tableWriter.AppendRow(row)
+	}
+	_, _ = fmt.Fprintln(writer, tableWriter.Render())
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+	"github.com/coder/terraform-eval/engine/hclext"
+)
+
+const consoleHelp = `Enter an expression to evaluate it, like 'local.image'. A line ending
+in '\' continues on the next line. In a terminal, the arrow keys move the
+cursor and go through the history of the session.
+
+Commands:
+  :set <name>=<value>  Set a parameter value, and evaluate the last expression again.
+  :unset <name>        Remove a parameter value.
+  :params              List the parameters and their values.
+  :refs <expression>   List the references of an expression.
+  :help                Show this help.
+  :quit                Exit the console, like Ctrl-D.
+`
+
+func (r *RootCmd) console() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "console",
+		Short: "Evaluate expressions interactively, like 'terraform console'.",
+		Handler: func(i *serpent.Invocation) error {
+			input, err := r.input()
+			if err != nil {
+				return err
+			}
+
+			dir, err := r.templateDir()
+			if err != nil {
+				return err
+			}
+
+			c := &console{
+				root:   r,
+				input:  input,
+				dir:    dir,
+				stdout: i.Stdout,
+				stderr: i.Stderr,
+			}
+			err = c.parse(i.Context())
+			if err != nil {
+				return err
+			}
+			return c.run(i.Context(), i.Stdin)
+		},
+	}
+	return cmd
+}
+
+// console is the state of a 'codertf console' session. The template is
+// parsed again whenever the input changes.
+type console struct {
+	root    *RootCmd
+	input   coderism.Input
+	dir     fs.FS
+	modules terraform.Modules
+	// last is the last expression that was evaluated.
+	last string
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (c *console) parse(ctx context.Context) error {
+	psr, modules, _, err := engine.ParseTerraform(ctx, c.input, c.dir)
+	if err != nil {
+		return fmt.Errorf("parse tf: %w", err)
+	}
+	c.root.Parser = psr
+	c.modules = modules
+	return nil
+}
+
+// run reads lines until the input ends. A terminal on Linux or macOS is
+// read with the line editor, other input is read line by line.
+func (c *console) run(ctx context.Context, stdin io.Reader) error {
+	readLine := c.lineReader(stdin)
+	prompt := "> "
+	var pending strings.Builder
+	for {
+		line, err := readLine(prompt)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		eof := errors.Is(err, io.EOF)
+
+		if strings.HasSuffix(line, `\`) && !eof {
+			pending.WriteString(strings.TrimSuffix(line, `\`) + "\n")
+			prompt = ". "
+			continue
+		}
+		pending.WriteString(line)
+		src := strings.TrimSpace(pending.String())
+		pending.Reset()
+		prompt = "> "
+
+		if src != "" {
+			if quit := c.exec(ctx, src); quit {
+				return nil
+			}
+		}
+		if eof {
+			_, _ = fmt.Fprintln(c.stdout)
+			return nil
+		}
+	}
+}
+
+// lineReader returns a function that writes the prompt and reads a line,
+// without the line ending.
+func (c *console) lineReader(stdin io.Reader) func(prompt string) (string, error) {
+	if f, ok := stdin.(*os.File); ok && isTerminal(f.Fd()) {
+		editor := newLineEditor(f, c.stdout)
+		return func(prompt string) (string, error) {
+			// Only the line is read in raw mode, so the output of the
+			// commands is written as usual.
+			restore, err := makeRaw(f.Fd())
+			if err != nil {
+				return "", err
+			}
+			defer restore()
+			return editor.readLine(prompt)
+		}
+	}
+
+	reader := bufio.NewReader(stdin)
+	return func(prompt string) (string, error) {
+		_, _ = fmt.Fprint(c.stdout, prompt)
+		line, err := reader.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+}
+
+// exec runs a command or evaluates an expression, and reports if the session
+// ends. Mistakes are reported to the user and do not end the session.
+func (c *console) exec(ctx context.Context, src string) bool {
+	if !strings.HasPrefix(src, ":") {
+		c.last = src
+		c.evaluate(src)
+		return false
+	}
+
+	command, args, _ := strings.Cut(src, " ")
+	args = strings.TrimSpace(args)
+	switch command {
+	case ":quit", ":exit", ":q":
+		return true
+	case ":help", ":h":
+		_, _ = fmt.Fprint(c.stdout, consoleHelp)
+	case ":set":
+		name, value, ok := strings.Cut(args, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			_, _ = fmt.Fprintln(c.stderr, "usage: :set <name>=<value>")
+			return false
+		}
+		if !c.setParameter(ctx, name, &value) {
+			return false
+		}
+		if c.last != "" {
+			c.evaluate(c.last)
+		}
+	case ":unset":
+		if args == "" {
+			_, _ = fmt.Fprintln(c.stderr, "usage: :unset <name>")
+			return false
+		}
+		c.setParameter(ctx, args, nil)
+	case ":params":
+		output, _ := coderism.Extract(c.modules, c.root.Parser.Files(), c.input)
+		clidisplay.Parameters(c.stdout, output.Parameters)
+	case ":refs":
+		expr, diags := c.parseExpression(args)
+		if diags.HasErrors() {
+			clidisplay.WriteDiagnostics(c.stderr, c.root.Parser, diags)
+			return false
+		}
+		for _, ref := range hclext.ReferenceNames(expr) {
+			_, _ = fmt.Fprintln(c.stdout, ref)
+		}
+	default:
+		_, _ = fmt.Fprintf(c.stderr, "unknown command %q, see :help\n", command)
+	}
+	return false
+}
+
+func (c *console) evaluate(src string) {
+	expr, diags := c.parseExpression(src)
+	eval := clidisplay.Evaluation{Expression: src}
+	if !diags.HasErrors() {
+		var valDiags hcl.Diagnostics
+		eval.Value, valDiags = coderism.Evaluate(c.modules, expr)
+		diags = diags.Extend(valDiags)
+	}
+
+	clidisplay.Evaluations(c.stdout, []clidisplay.Evaluation{eval})
+	if len(diags) > 0 {
+		clidisplay.WriteDiagnostics(c.stderr, c.root.Parser, diags)
+	}
+}
+
+func (c *console) parseExpression(src string) (hcl.Expression, hcl.Diagnostics) {
+	return hclsyntax.ParseExpression([]byte(src), "<console>", hcl.InitialPos)
+}
+
+// setParameter replaces the value of the parameter, and parses the template
+// again. A nil value removes it. If the template does not parse, the error
+// is written and the previous values are kept, so the session goes on.
+func (c *console) setParameter(ctx context.Context, name string, value *string) bool {
+	previous := c.input
+	values := make([]*proto.RichParameterValue, 0, len(c.input.ParameterValues)+1)
+	for _, pv := range c.input.ParameterValues {
+		if pv.Name != name {
+			values = append(values, pv)
+		}
+	}
+	if value != nil {
+		values = append(values, &proto.RichParameterValue{Name: name, Value: *value})
+	}
+	c.input.ParameterValues = values
+
+	if err := c.parse(ctx); err != nil {
+		_, _ = fmt.Fprintln(c.stderr, err.Error())
+		c.input = previous
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// lineEditor reads lines from a terminal in raw mode. The cursor moves with
+// the arrow keys, Home, End, Ctrl-A and Ctrl-E, and the up and down arrows
+// go through the lines entered before. Ctrl-C discards the line, and Ctrl-D
+// on an empty line ends the input.
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+// readLine reads a line, without the line ending. It returns io.EOF if the
+// input ends before the line does.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	// hist is the index of the history entry being edited, and draft is
+	// the new line while an older entry is shown.
+	hist := len(e.history)
+	var draft []rune
+
+	redraw := func() {
+		// Rewrite the whole line, clear the rest of it, and move the
+		// cursor back to its position.
+		_, _ = fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if n := len(line) - pos; n > 0 {
+			_, _ = fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	showHistory := func(i int) {
+		if hist == len(e.history) {
+			draft = line
+		}
+		hist = i
+		line = draft
+		if i < len(e.history) {
+			line = []rune(e.history[i])
+		}
+		pos = len(line)
+	}
+
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(line), err
+		}
+
+		switch r {
+		case '\r', '\n':
+			_, _ = fmt.Fprint(e.out, "\r\n")
+			text := string(line)
+			if strings.TrimSpace(text) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != text) {
+				e.history = append(e.history, text)
+			}
+			return text, nil
+		case 0x03: // Ctrl-C
+			_, _ = fmt.Fprint(e.out, "^C\r\n")
+			line, pos, hist = nil, 0, len(e.history)
+		case 0x04: // Ctrl-D
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 0x7f, 0x08: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 0x01: // Ctrl-A
+			pos = 0
+		case 0x05: // Ctrl-E
+			pos = len(line)
+		case 0x1b:
+			switch e.escapeSequence() {
+			case "[A", "OA": // Up
+				if hist > 0 {
+					showHistory(hist - 1)
+				}
+			case "[B", "OB": // Down
+				if hist < len(e.history) {
+					showHistory(hist + 1)
+				}
+			case "[C", "OC": // Right
+				if pos < len(line) {
+					pos++
+				}
+			case "[D", "OD": // Left
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~": // Home
+				pos = 0
+			case "[F", "OF", "[4~": // End
+				pos = len(line)
+			case "[3~": // Delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		redraw()
+	}
+}
+
+// escapeSequence reads the rest of an escape sequence, like '[A' for the up
+// arrow. Sequences that are not known are read and ignored.
+func (e *lineEditor) escapeSequence() string {
+	var seq strings.Builder
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return seq.String()
+		}
+		seq.WriteByte(b)
+		s := seq.String()
+		switch {
+		case s == "[" || s == "O":
+			// The introducer, the rest follows.
+		case s[0] == 'O' && len(s) == 2:
+			return s
+		case b >= 0x40 && b <= 0x7e:
+			// A control sequence ends with a byte in this range.
+			return s
+		case s[0] != '[' && s[0] != 'O':
+			// Alt and a key, which is not bound.
+			return s
+		}
+	}
+}
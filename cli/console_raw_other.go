@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package cli
+
+import "errors"
+
+// Line editing is only supported on Linux and macOS. Elsewhere the console
+// reads whole lines, like from a pipe.
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func TestConsole(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		data "coder_parameter" "region" {
+			name    = "region"
+			default = "us"
+		}
+		locals {
+			zone = "${data.coder_parameter.region.value}-1"
+		}`
+
+	for _, tc := range []struct {
+		name         string
+		stdin        string
+		expectStdout []string
+		expectStderr []string
+		// rejectStdout is output that must not be written.
+		rejectStdout []string
+	}{
+		{
+			name:         "expression",
+			stdin:        "local.zone\n",
+			expectStdout: []string{`local.zone = "us-1" (string)`},
+		},
+		{
+			name:         "no trailing newline",
+			stdin:        "upper(local.zone)",
+			expectStdout: []string{`upper(local.zone) = "US-1" (string)`},
+		},
+		{
+			name:  "set trims the value",
+			stdin: "local.zone\n:set region = eu \n",
+			expectStdout: []string{
+				`local.zone = "us-1" (string)`,
+				`local.zone = "eu-1" (string)`,
+			},
+		},
+		{
+			name:         "unset",
+			stdin:        ":set region=eu\n:unset region\nlocal.zone\n",
+			expectStdout: []string{`local.zone = "us-1" (string)`},
+		},
+		{
+			name:         "continued line",
+			stdin:        "join(\"/\", [\\\nlocal.zone])\n",
+			expectStdout: []string{`= "us-1" (string)`},
+		},
+		{
+			name:         "refs",
+			stdin:        ":refs local.zone\n",
+			expectStdout: []string{"local.zone"},
+		},
+		{
+			name:         "quit",
+			stdin:        ":quit\nlocal.zone\n",
+			rejectStdout: []string{"us-1"},
+		},
+		{
+			name:         "unknown command",
+			stdin:        ":nope\n",
+			expectStderr: []string{`unknown command ":nope"`},
+		},
+		{
+			name:         "set usage",
+			stdin:        ":set region\n",
+			expectStderr: []string{"usage: :set <name>=<value>"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+
+			var stdout, stderr bytes.Buffer
+			c := &console{
+				root:   &RootCmd{},
+				input:  coderism.Input{},
+				dir:    afero.NewIOFS(memfs),
+				stdout: &stdout,
+				stderr: &stderr,
+			}
+			ctx := context.Background()
+			require.NoError(t, c.parse(ctx))
+			require.NoError(t, c.run(ctx, strings.NewReader(tc.stdin)))
+
+			for _, expect := range tc.expectStdout {
+				require.Contains(t, stdout.String(), expect)
+			}
+			for _, expect := range tc.expectStderr {
+				require.Contains(t, stderr.String(), expect)
+			}
+			for _, reject := range tc.rejectStdout {
+				require.NotContains(t, stdout.String(), reject)
+			}
+		})
+	}
+}
+
+func TestConsole_ParseError(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(`
+		data "coder_parameter" "region" {
+			name    = "region"
+			default = "us"
+		}`), 0644)
+	require.NoError(t, err)
+
+	dir := &failingFS{FS: afero.NewIOFS(memfs)}
+	var stdout, stderr bytes.Buffer
+	c := &console{
+		root:   &RootCmd{},
+		input:  coderism.Input{},
+		dir:    dir,
+		stdout: &stdout,
+		stderr: &stderr,
+	}
+	ctx := context.Background()
+	require.NoError(t, c.parse(ctx))
+
+	// The template can no longer be read, so the value is not set and the
+	// session goes on.
+	dir.fail = true
+	require.NoError(t, c.run(ctx, strings.NewReader(":set region=eu\n:unset region\ndata.coder_parameter.region.value\n")))
+
+	require.Contains(t, stderr.String(), "parse tf")
+	require.Empty(t, c.input.ParameterValues)
+	require.Contains(t, stdout.String(), `data.coder_parameter.region.value = "us" (string)`)
+}
+
+// failingFS fails to open any file once fail is set.
+type failingFS struct {
+	fs.FS
+	fail bool
+}
+
+func (f *failingFS) Open(name string) (fs.File, error) {
+	if f.fail {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.FS.Open(name)
+}
+
+func TestLineEditor(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		keys   string
+		expect []string
+	}{
+		{
+			name:   "insert at the cursor",
+			keys:   "abc\x1b[D\x1b[DX\r",
+			expect: []string{"aXbc"},
+		},
+		{
+			name:   "backspace and delete",
+			keys:   "abcd\x7f\x1b[D\x1b[D\x1b[3~\r",
+			expect: []string{"ac"},
+		},
+		{
+			name:   "home and end",
+			keys:   "bc\x01a\x05d\x1b[Hz\r",
+			expect: []string{"zabcd"},
+		},
+		{
+			name:   "history",
+			keys:   "one\rtwo\r\x1b[A\x1b[A\r\x1b[Ax\x1b[B\r",
+			expect: []string{"one", "two", "one", ""},
+		},
+		{
+			name:   "history keeps the new line",
+			keys:   "one\rnew\x1b[A\x1b[B!\r",
+			expect: []string{"one", "new!"},
+		},
+		{
+			name:   "ctrl-c discards the line",
+			keys:   "abc\x03def\r",
+			expect: []string{"def"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			editor := newLineEditor(strings.NewReader(tc.keys), io.Discard)
+			var lines []string
+			for {
+				line, err := editor.readLine("> ")
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				lines = append(lines, line)
+			}
+			require.Equal(t, tc.expect, lines)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func (r *RootCmd) eval() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:        "eval <expression>...",
+		Short:      "Evaluate expressions with the variables, locals, parameters and data sources of the template.",
+		Middleware: serpent.RequireRangeArgs(1, -1),
+		Handler: func(i *serpent.Invocation) error {
+			modules, _, err := r.parse(i)
+			if err != nil {
+				return err
+			}
+
+			evals := evaluateExpressions(i.Args, func(expr hcl.Expression) (cty.Value, hcl.Diagnostics) {
+				return coderism.Evaluate(modules, expr)
+			})
+			return r.writeEvaluations(evals, nil)
+		},
+	}
+	return cmd
+}
+
+// evaluateExpressions parses and evaluates every expression. The
+// diagnostics of an expression are in its evaluation.
+func evaluateExpressions(args []string, evaluate func(expr hcl.Expression) (cty.Value, hcl.Diagnostics)) []clidisplay.Evaluation {
+	evals := make([]clidisplay.Evaluation, 0, len(args))
+	for n, arg := range args {
+		eval := clidisplay.Evaluation{Expression: arg}
+		expr, exprDiags := hclsyntax.ParseExpression([]byte(arg), fmt.Sprintf("<expression %d>", n+1), hcl.InitialPos)
+		eval.Diagnostics = exprDiags
+		if !exprDiags.HasErrors() {
+			var valDiags hcl.Diagnostics
+			eval.Value, valDiags = evaluate(expr)
+			eval.Diagnostics = eval.Diagnostics.Extend(valDiags)
+		}
+		evals = append(evals, eval)
+	}
+	return evals
+}
+
+// writeEvaluations writes the evaluations in the output format, with the
+// diagnostics of the template. Errors are returned after the output is
+// written, so the exit code is not zero in either format.
+func (r *RootCmd) writeEvaluations(evals []clidisplay.Evaluation, diags hcl.Diagnostics) error {
+	if r.output == "json" {
+		err := clidisplay.EvaluationsJSON(os.Stdout, evals, diags)
+		if err != nil {
+			return err
+		}
+	} else {
+		clidisplay.Evaluations(os.Stdout, evals)
+	}
+
+	for _, eval := range evals {
+		diags = diags.Extend(eval.Diagnostics)
+	}
+
+	if diags.HasErrors() {
+		// main writes the diagnostics of the error.
+		return fmt.Errorf("evaluate expressions: %w", diags)
+	}
+	if len(diags) > 0 && r.output != "json" {
+		// The JSON output has the diagnostics.
+		_, _ = fmt.Fprintf(os.Stderr, "Evaluation Diagnostics:\n")
+		clidisplay.WriteDiagnostics(os.Stderr, r.Parser, diags)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func (r *RootCmd) graph() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "graph",
+		Short: "Print the graph of what each parameter depends on, as DOT or JSON with '--output json'.",
+		Handler: func(i *serpent.Invocation) error {
+			modules, _, err := r.parse(i)
+			if err != nil {
+				return err
+			}
+
+			graph := coderism.ParameterDependencies(modules)
+			if r.output == "json" {
+				return clidisplay.CommandJSON(os.Stdout, "graph", graph, nil)
+			}
+
+			_, _ = fmt.Fprint(os.Stdout, graph.DOT())
+			return nil
+		},
+	}
+	return cmd
+}
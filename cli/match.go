@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func (r *RootCmd) match() *serpent.Command {
+	var provisioners string
+	cmd := &serpent.Command{
+		Use:   "match",
+		Short: "Show which provisioner daemons would accept the workspace build.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "provisioners",
+				Description: "JSON file with the provisioner daemons, as a list of {\"name\": \"\", \"tags\": {}} objects.",
+				Flag:        "provisioners",
+				Required:    true,
+				Value:       serpent.StringOf(&provisioners),
+			},
+		},
+		Handler: func(i *serpent.Invocation) error {
+			daemons, err := readProvisionersFile(provisioners)
+			if err != nil {
+				return err
+			}
+
+			modules, input, err := r.parse(i)
+			if err != nil {
+				return err
+			}
+
+			output, diags := coderism.Extract(modules, r.Parser.Files(), input)
+			if len(diags) > 0 {
+				_, _ = fmt.Fprintf(os.Stderr, "Parsing Diagnostics:\n")
+				clidisplay.WriteDiagnostics(os.Stderr, r.Parser, diags)
+			}
+
+			matches, err := output.WorkspaceTags.MatchProvisioners(daemons, input.DataSources.WorkspaceOwner.ID)
+			if err != nil {
+				return fmt.Errorf("match provisioners: %w", err)
+			}
+
+			if r.output == "json" {
+				err = clidisplay.CommandJSON(os.Stdout, "match", matches, diags)
+				if err != nil {
+					return err
+				}
+			} else {
+				clidisplay.ProvisionerMatches(os.Stdout, matches)
+			}
+
+			for _, m := range matches {
+				if m.Status != coderism.MatchExcluded {
+					return nil
+				}
+			}
+			return fmt.Errorf("no provisioner daemon can build the workspace")
+		},
+	}
+	return cmd
+}
+
+func readProvisionersFile(path string) ([]coderism.ProvisionerDaemon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	var daemons []coderism.ProvisionerDaemon
+	err = json.Unmarshal(data, &daemons)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal %q: %w", path, err)
+	}
+	return daemons, nil
+}
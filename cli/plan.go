@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func (r *RootCmd) plan() *serpent.Command {
+	var planFile string
+	cmd := &serpent.Command{
+		Use:   "plan",
+		Short: "Compare the previewed workspace tags and parameter values with a terraform plan.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "plan",
+				Description: "Output of 'terraform show -json' of a plan file.",
+				Flag:        "plan",
+				Required:    true,
+				Value:       serpent.StringOf(&planFile),
+			},
+		},
+		Handler: func(i *serpent.Invocation) error {
+			plan, err := engine.ParseTFPlan(os.DirFS(filepath.Dir(planFile)), filepath.Base(planFile))
+			if err != nil {
+				return err
+			}
+
+			modules, input, err := r.parse(i)
+			if err != nil {
+				return err
+			}
+
+			output, diags := coderism.Extract(modules, r.Parser.Files(), input)
+			if len(diags) > 0 {
+				_, _ = fmt.Fprintf(os.Stderr, "Parsing Diagnostics:\n")
+				clidisplay.WriteDiagnostics(os.Stderr, r.Parser, diags)
+			}
+
+			comparison := engine.ComparePlan(output, plan)
+
+			if r.output == "json" {
+				err = clidisplay.CommandJSON(os.Stdout, "plan", comparison, diags)
+				if err != nil {
+					return err
+				}
+			} else {
+				clidisplay.PlanComparison(os.Stdout, comparison)
+			}
+
+			if diffs := comparison.Differences(); len(diffs) > 0 {
+				return fmt.Errorf("%d values differ from the plan", len(diffs))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
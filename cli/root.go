@@ -1,13 +1,17 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
 	"github.com/aquasecurity/trivy/pkg/iac/scanners/terraform/parser"
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
 
 	"github.com/coder/serpent"
 	"github.com/coder/terraform-eval/cli/clidisplay"
@@ -19,16 +23,29 @@ import (
 
 type RootCmd struct {
 	Parser *parser.Parser
+
+	// Flags shared by every subcommand.
+	dir          string
+	vars         []string
+	prevValues   string
+	dataSources  string
+	dataFixtures string
+	state        string
+	moduleCache  string
+	transition   string
+	workspace    coderism.WorkspaceData
+	owner        coderism.WorkspaceOwnerData
+	provisioner  coderism.ProvisionerData
+	// output is the output format of the root command and the subcommands
+	// that have a table and a JSON output.
+	output string
 }
 
 func (r *RootCmd) Root() *serpent.Command {
-	var (
-		dir  string
-		vars []string
-	)
 	cmd := &serpent.Command{
-		Use:   "codertf",
+		Use:   "codertf [expression...]",
 		Short: "codertf is a command line tool for previewing terraform template outputs.",
+		Long:  "With expressions, codertf evaluates them with the terraform evaluator of tflint, with the variables and locals of the template and the parameter values as variables, instead of writing the preview.",
 		Options: serpent.OptionSet{
 			{
 				Name:          "dir",
@@ -36,7 +53,7 @@ func (r *RootCmd) Root() *serpent.Command {
 				Flag:          "dir",
 				FlagShorthand: "d",
 				Default:       ".",
-				Value:         serpent.StringOf(&dir),
+				Value:         serpent.StringOf(&r.dir),
 			},
 			{
 				Name:          "vars",
@@ -44,85 +61,310 @@ func (r *RootCmd) Root() *serpent.Command {
 				Flag:          "vars",
 				FlagShorthand: "v",
 				Default:       ".",
-				Value:         serpent.StringArrayOf(&vars),
+				Value:         serpent.StringArrayOf(&r.vars),
+			},
+			{
+				Name:        "previous-values",
+				Description: "JSON file with the parameter values of the previous workspace build, as a list of {\"name\": \"\", \"value\": \"\"} objects.",
+				Flag:        "previous-values",
+				Value:       serpent.StringOf(&r.prevValues),
+			},
+			{
+				Name:        "data-sources",
+				Description: "JSON file with the values of the coder data sources, as {\"workspace\": {}, \"workspace_owner\": {}, \"provisioner\": {}}. Missing values use defaults.",
+				Flag:        "data-sources",
+				Value:       serpent.StringOf(&r.dataSources),
+			},
+			{
+				Name:        "data-fixtures",
+				Description: "HCL or JSON file with the values of other data sources, as 'data \"type\" \"name\" { attr = value }' blocks.",
+				Flag:        "data-fixtures",
+				Value:       serpent.StringOf(&r.dataFixtures),
+			},
+			{
+				Name:        "workspace-name",
+				Description: "Name of the workspace, for 'data.coder_workspace'.",
+				Flag:        "workspace-name",
+				Value:       serpent.StringOf(&r.workspace.Name),
+			},
+			{
+				Name:        "state",
+				Description: "Output of 'terraform show -json' for the state or plan of an existing workspace. Its resource values are used for attributes that are otherwise not known.",
+				Flag:        "state",
+				Value:       serpent.StringOf(&r.state),
+			},
+			{
+				Name:        "module-cache",
+				Description: "Directory with modules by source and version, like 'registry.coder.com/modules/code-server/coder/1.0.26'. Modules that 'terraform init' has not downloaded are loaded from it.",
+				Flag:        "module-cache",
+				Value:       serpent.StringOf(&r.moduleCache),
+			},
+			{
+				Name:        "transition",
+				Description: "Transition of the workspace build, which sets 'data.coder_workspace' 'transition' and 'start_count'.",
+				Flag:        "transition",
+				Value:       serpent.EnumOf(&r.transition, coderism.Transitions...),
+			},
+			{
+				Name:        "owner-name",
+				Description: "Username of the workspace owner, for 'data.coder_workspace_owner'.",
+				Flag:        "owner-name",
+				Value:       serpent.StringOf(&r.owner.Name),
+			},
+			{
+				Name:        "owner-email",
+				Description: "Email of the workspace owner, for 'data.coder_workspace_owner'.",
+				Flag:        "owner-email",
+				Value:       serpent.StringOf(&r.owner.Email),
+			},
+			{
+				Name:        "owner-groups",
+				Description: "Groups of the workspace owner, for 'data.coder_workspace_owner'.",
+				Flag:        "owner-groups",
+				Value:       serpent.StringArrayOf(&r.owner.Groups),
+			},
+			{
+				Name:        "provisioner-os",
+				Description: "Operating system of the provisioner, for 'data.coder_provisioner'.",
+				Flag:        "provisioner-os",
+				Value:       serpent.StringOf(&r.provisioner.OS),
+			},
+			{
+				Name:        "provisioner-arch",
+				Description: "Architecture of the provisioner, for 'data.coder_provisioner'.",
+				Flag:        "provisioner-arch",
+				Value:       serpent.StringOf(&r.provisioner.Arch),
+			},
+			{
+				Name:          "output",
+				Description:   "Output format. The graph subcommand writes DOT for 'table' and 'dot', other commands write a table for 'dot'.",
+				Flag:          "output",
+				FlagShorthand: "o",
+				Default:       "table",
+				Value:         serpent.EnumOf(&r.output, "table", "json", "dot"),
 			},
 		},
 		Handler: func(i *serpent.Invocation) error {
-			dfs := os.DirFS(dir)
-
-			var rvars []*proto.RichParameterValue
-			for _, val := range vars {
-				parts := strings.Split(val, "=")
-				if len(parts) != 2 {
-					continue
-				}
-				rvars = append(rvars, &proto.RichParameterValue{
-					Name:  parts[0],
-					Value: parts[1],
-				})
-			}
-
-			input := coderism.Input{
-				ParameterValues: rvars,
+			modules, input, err := r.parse(i)
+			if err != nil {
+				return err
 			}
 
-			psr, modules, _, err := engine.ParseTerraform(i.Context(), input, dfs)
-			if err != nil {
-				return fmt.Errorf("parse tf: %w", err)
+			if len(i.Args) > 0 {
+				return r.lintEvaluate(i, input)
 			}
-			r.Parser = psr
 
 			// TODO: Implement the parameter cli resolver in this package
-			output, diags := coderism.Extract(modules, input)
+			output, diags := coderism.Extract(modules, r.Parser.Files(), input)
 
-			if len(i.Args) > 0 {
-				eval, _, ptDiags := lintengine.ParseTerraform(i.Context(), input, dfs)
-				if ptDiags.HasErrors() {
-					return fmt.Errorf("parse lint: %w", ptDiags)
+			if r.output == "json" {
+				err := clidisplay.JSON(os.Stdout, output, diags)
+				if err != nil {
+					return err
 				}
-
-				var _ = eval
-				//for _, arg := range i.Args {
-				//	fmt.Printf("Evaluating: %s\n", arg)
-				//	v, vdiags := eval.EvaluateExpr(hclExpr(arg), cty.String)
-				//	if vdiags.HasErrors() {
-				//		fmt.Println(vdiags.Error())
-				//		continue
-				//	}
-				//	fmt.Printf("Evaluated: %s\n", v.AsString())
-				//}
-				//for _, param := range output.Parameters {
-
-				//}
+				if diags.HasErrors() {
+					// The document has the diagnostics, the error only
+					// makes the exit code not zero.
+					return fmt.Errorf("extract: %w", diags)
+				}
+				return nil
 			}
 
 			if len(diags) > 0 {
 				_, _ = fmt.Fprintf(os.Stderr, "Parsing Diagnostics:\n")
-				clidisplay.WriteDiagnostics(os.Stderr, psr, diags)
+				clidisplay.WriteDiagnostics(os.Stderr, r.Parser, diags)
 			}
 
 			diags = clidisplay.WorkspaceTags(os.Stdout, output.WorkspaceTags)
 			if len(diags) > 0 {
 				_, _ = fmt.Fprintf(os.Stderr, "Workspace Tags Diagnostics:\n")
-				clidisplay.WriteDiagnostics(os.Stderr, psr, diags)
+				clidisplay.WriteDiagnostics(os.Stderr, r.Parser, diags)
 			}
 
 			clidisplay.Parameters(os.Stdout, output.Parameters)
+			clidisplay.Resources(os.Stdout, output.Resources)
 
 			return nil
 		},
+		Children: []*serpent.Command{
+			r.graph(),
+			r.match(),
+			r.tagSets(),
+			r.transitions(),
+			r.plan(),
+			r.eval(),
+			r.console(),
+		},
 	}
 	return cmd
 }
 
-func hclExpr(expr string) hcl.Expression {
-	file, diags := hclsyntax.ParseConfig([]byte(fmt.Sprintf(`expr = %s`, expr)), "test.tf", hcl.InitialPos)
-	if diags.HasErrors() {
-		panic(diags)
+// lintEvaluate evaluates the positional arguments with the lintengine
+// evaluator.
+func (r *RootCmd) lintEvaluate(i *serpent.Invocation, input coderism.Input) error {
+	dir, err := r.templateDir()
+	if err != nil {
+		return err
+	}
+
+	eval, _, ptDiags := lintengine.ParseTerraform(i.Context(), input, dir)
+	if ptDiags.HasErrors() {
+		return fmt.Errorf("parse lint: %w", ptDiags)
 	}
-	attributes, diags := file.Body.JustAttributes()
-	if diags.HasErrors() {
-		panic(diags)
+
+	evals := evaluateExpressions(i.Args, func(expr hcl.Expression) (cty.Value, hcl.Diagnostics) {
+		return eval.EvaluateExpr(expr, cty.DynamicPseudoType)
+	})
+	return r.writeEvaluations(evals, ptDiags)
+}
+
+// input builds the coderism input from the shared flags.
+func (r *RootCmd) input() (coderism.Input, error) {
+	var rvars []*proto.RichParameterValue
+	for _, val := range r.vars {
+		parts := strings.Split(val, "=")
+		if len(parts) != 2 {
+			continue
+		}
+		rvars = append(rvars, &proto.RichParameterValue{
+			Name:  parts[0],
+			Value: parts[1],
+		})
+	}
+
+	var prevVars []*proto.RichParameterValue
+	if r.prevValues != "" {
+		var err error
+		prevVars, err = readParameterValuesFile(r.prevValues)
+		if err != nil {
+			return coderism.Input{}, fmt.Errorf("previous values: %w", err)
+		}
+	}
+
+	dataSources, err := r.readDataSources()
+	if err != nil {
+		return coderism.Input{}, err
+	}
+
+	var fixtures []coderism.DataFixture
+	if r.dataFixtures != "" {
+		data, err := os.ReadFile(r.dataFixtures)
+		if err != nil {
+			return coderism.Input{}, fmt.Errorf("read %q: %w", r.dataFixtures, err)
+		}
+		var diags hcl.Diagnostics
+		fixtures, diags = coderism.ParseDataFixtures(r.dataFixtures, data)
+		if diags.HasErrors() {
+			return coderism.Input{}, fmt.Errorf("data fixtures: %w", diags)
+		}
+	}
+
+	var state *tfjson.State
+	if r.state != "" {
+		data, err := os.ReadFile(r.state)
+		if err != nil {
+			return coderism.Input{}, fmt.Errorf("read %q: %w", r.state, err)
+		}
+		state, err = engine.ParseTFState(data)
+		if err != nil {
+			return coderism.Input{}, fmt.Errorf("state %q: %w", r.state, err)
+		}
+	}
+
+	return coderism.Input{
+		ParameterValues:         rvars,
+		PreviousParameterValues: prevVars,
+		DataSources:             dataSources,
+		DataFixtures:            fixtures,
+		State:                   state,
+	}, nil
+}
+
+// readDataSources reads the data sources file over the defaults, and then
+// applies the flags.
+func (r *RootCmd) readDataSources() (coderism.DataSources, error) {
+	sources := coderism.DefaultDataSources()
+	if r.dataSources != "" {
+		data, err := os.ReadFile(r.dataSources)
+		if err != nil {
+			return sources, fmt.Errorf("read %q: %w", r.dataSources, err)
+		}
+		// Decoding into the defaults keeps the fields the file does not set.
+		err = json.Unmarshal(data, &sources)
+		if err != nil {
+			return sources, fmt.Errorf("unmarshal %q: %w", r.dataSources, err)
+		}
+		sources = sources.WithDefaults()
+	}
+
+	if r.workspace.Name != "" {
+		sources.Workspace.Name = r.workspace.Name
+	}
+	if r.transition != "" {
+		workspace := sources.Workspace.WithTransition(r.transition)
+		sources.Workspace = &workspace
+	}
+	if r.owner.Name != "" {
+		sources.WorkspaceOwner.Name = r.owner.Name
+	}
+	if r.owner.Email != "" {
+		sources.WorkspaceOwner.Email = r.owner.Email
+	}
+	if len(r.owner.Groups) > 0 {
+		sources.WorkspaceOwner.Groups = r.owner.Groups
+	}
+	if r.provisioner.OS != "" {
+		sources.Provisioner.OS = r.provisioner.OS
+	}
+	if r.provisioner.Arch != "" {
+		sources.Provisioner.Arch = r.provisioner.Arch
+	}
+	return sources, nil
+}
+
+// parse evaluates the terraform in the directory given by the shared flags.
+func (r *RootCmd) parse(i *serpent.Invocation) (terraform.Modules, coderism.Input, error) {
+	input, err := r.input()
+	if err != nil {
+		return nil, coderism.Input{}, err
+	}
+
+	dir, err := r.templateDir()
+	if err != nil {
+		return nil, coderism.Input{}, err
+	}
+
+	psr, modules, _, err := engine.ParseTerraform(i.Context(), input, dir)
+	if err != nil {
+		return nil, coderism.Input{}, fmt.Errorf("parse tf: %w", err)
+	}
+	r.Parser = psr
+	return modules, input, nil
+}
+
+// templateDir is the directory with the terraform files, with the modules of
+// the module cache.
+func (r *RootCmd) templateDir() (fs.FS, error) {
+	if r.moduleCache == "" {
+		return os.DirFS(r.dir), nil
+	}
+	dir, err := engine.WithModuleCache(os.DirFS(r.dir), os.DirFS(r.moduleCache))
+	if err != nil {
+		return nil, fmt.Errorf("module cache %q: %w", r.moduleCache, err)
+	}
+	return dir, nil
+}
+
+func readParameterValuesFile(path string) ([]*proto.RichParameterValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	var values []*proto.RichParameterValue
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal %q: %w", path, err)
 	}
-	return attributes["expr"].Expr
+	return values, nil
 }
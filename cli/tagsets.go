@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine"
+)
+
+func (r *RootCmd) tagSets() *serpent.Command {
+	var (
+		maxCombinations int64
+		seed            int64
+	)
+	cmd := &serpent.Command{
+		Use:   "tagsets",
+		Short: "List every set of workspace tags reachable by the parameter options.",
+		Options: serpent.OptionSet{
+			{
+				Name:        "max-combinations",
+				Description: "Maximum number of parameter combinations to evaluate. Larger templates are sampled.",
+				Flag:        "max-combinations",
+				Default:     "256",
+				Value:       serpent.Int64Of(&maxCombinations),
+			},
+			{
+				Name:        "seed",
+				Description: "Seed of the random sample of parameter combinations.",
+				Flag:        "seed",
+				Default:     "0",
+				Value:       serpent.Int64Of(&seed),
+			},
+		},
+		Handler: func(i *serpent.Invocation) error {
+			input, err := r.input()
+			if err != nil {
+				return err
+			}
+
+			dir, err := r.templateDir()
+			if err != nil {
+				return err
+			}
+
+			report, err := engine.WorkspaceTagSets(i.Context(), input, dir, engine.TagSetOptions{
+				MaxCombinations: int(maxCombinations),
+				Seed:            seed,
+			})
+			if err != nil {
+				return err
+			}
+
+			if r.output == "json" {
+				return clidisplay.CommandJSON(os.Stdout, "tagsets", report, nil)
+			}
+
+			clidisplay.TagSets(os.Stdout, report)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/coder/serpent"
+	"github.com/coder/terraform-eval/cli/clidisplay"
+	"github.com/coder/terraform-eval/engine"
+)
+
+func (r *RootCmd) transitions() *serpent.Command {
+	cmd := &serpent.Command{
+		Use:   "transitions",
+		Short: "Compare the resources, agents and workspace tags of the start, stop and delete transitions.",
+		Handler: func(i *serpent.Invocation) error {
+			input, err := r.input()
+			if err != nil {
+				return err
+			}
+
+			dir, err := r.templateDir()
+			if err != nil {
+				return err
+			}
+
+			report, err := engine.CompareTransitions(i.Context(), input, dir)
+			if err != nil {
+				return err
+			}
+
+			if r.output == "json" {
+				return clidisplay.CommandJSON(os.Stdout, "transitions", report, nil)
+			}
+
+			clidisplay.Transitions(os.Stdout, report)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/aquasecurity/trivy/pkg/iac/terraform"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
 )
 
 type attributeParser struct {
@@ -83,6 +85,56 @@ func (a *expectedAttribute) bool() bool {
 	return attr.Value().True()
 }
 
+// primitiveString is like string, but also accepts numbers and bools. This
+// matches terraform converting primitives for 'TypeString' schema fields.
+func (a *expectedAttribute) primitiveString() string {
+	attr := a.p.block.GetAttribute(a.key)
+	if attr.IsNil() || attr.Value().IsNull() {
+		return ""
+	}
+	val, err := convert.Convert(attr.Value(), cty.String)
+	if err != nil || !val.IsKnown() {
+		a.expectedTypeError(attr, "string")
+		return ""
+	}
+	return val.AsString()
+}
+
+func (a *expectedAttribute) int32() int32 {
+	v := a.optionalInt32()
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// optionalInt32 returns nil if the attribute is not set.
+func (a *expectedAttribute) optionalInt32() *int32 {
+	attr := a.p.block.GetAttribute(a.key)
+	if attr.IsNil() || attr.Value().IsNull() {
+		return nil
+	}
+	if attr.Type() != cty.Number {
+		a.expectedTypeError(attr, "number")
+		return nil
+	}
+
+	var v int32
+	if err := gocty.FromCtyValue(attr.Value(), &v); err != nil {
+		a.error(hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid attribute value",
+			Detail:      fmt.Sprintf("The attribute %q must be a 32-bit integer: %s", a.key, err.Error()),
+			Subject:     &attr.HCLAttribute().Range,
+			Context:     &a.p.block.HCLBlock().DefRange,
+			Expression:  attr.HCLAttribute().Expr,
+			EvalContext: a.p.block.Context().Inner(),
+		})
+		return nil
+	}
+	return &v
+}
+
 func (a *expectedAttribute) expectedTypeError(attr *terraform.Attribute, expectedType string) {
 	a.error(hcl.Diagnostic{
 		Severity:   hcl.DiagError,
@@ -0,0 +1,191 @@
+package coderism
+
+import (
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DataSources are the values coderd gives the coder data sources when a
+// workspace is built. A nil field uses the defaults.
+type DataSources struct {
+	Workspace      *WorkspaceData      `json:"workspace,omitempty"`
+	WorkspaceOwner *WorkspaceOwnerData `json:"workspace_owner,omitempty"`
+	Provisioner    *ProvisionerData    `json:"provisioner,omitempty"`
+}
+
+// WorkspaceData is the 'coder_workspace' data source.
+type WorkspaceData struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	StartCount      int    `json:"start_count"`
+	Transition      string `json:"transition"`
+	AccessURL       string `json:"access_url"`
+	AccessPort      int    `json:"access_port"`
+	TemplateID      string `json:"template_id"`
+	TemplateName    string `json:"template_name"`
+	TemplateVersion string `json:"template_version"`
+	IsPrebuild      bool   `json:"is_prebuild"`
+}
+
+// WorkspaceOwnerData is the 'coder_workspace_owner' data source.
+type WorkspaceOwnerData struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	FullName     string   `json:"full_name"`
+	Email        string   `json:"email"`
+	Groups       []string `json:"groups"`
+	LoginType    string   `json:"login_type"`
+	SSHPublicKey string   `json:"ssh_public_key"`
+}
+
+// ProvisionerData is the 'coder_provisioner' data source.
+type ProvisionerData struct {
+	ID   string `json:"id"`
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+// The transitions of a workspace build, the 'transition' of the
+// 'coder_workspace' data source.
+const (
+	TransitionStart  = "start"
+	TransitionStop   = "stop"
+	TransitionDelete = "delete"
+)
+
+// Transitions are every transition, in the order of a workspace's life.
+var Transitions = []string{TransitionStart, TransitionStop, TransitionDelete}
+
+// WithTransition returns the workspace as it is built for the transition.
+// Like coderd, only a started workspace has a start_count of 1.
+func (w WorkspaceData) WithTransition(transition string) WorkspaceData {
+	w.Transition = transition
+	w.StartCount = 0
+	if transition == TransitionStart {
+		w.StartCount = 1
+	}
+	return w
+}
+
+// DefaultDataSources are the values used for the coder data sources when
+// none are given. They describe a running workspace of a default user.
+func DefaultDataSources() DataSources {
+	return DataSources{
+		Workspace: &WorkspaceData{
+			ID:              "00000000-0000-0000-0000-000000000000",
+			Name:            "default",
+			StartCount:      1,
+			Transition:      "start",
+			AccessURL:       "https://coder.example.com",
+			AccessPort:      443,
+			TemplateID:      "00000000-0000-0000-0000-000000000000",
+			TemplateName:    "default",
+			TemplateVersion: "default",
+		},
+		WorkspaceOwner: &WorkspaceOwnerData{
+			ID:        "00000000-0000-0000-0000-000000000000",
+			Name:      "default",
+			FullName:  "Default User",
+			Email:     "default@example.com",
+			Groups:    []string{},
+			LoginType: "password",
+		},
+		Provisioner: &ProvisionerData{
+			ID:   "00000000-0000-0000-0000-000000000000",
+			OS:   "linux",
+			Arch: "amd64",
+		},
+	}
+}
+
+// WithDefaults returns the data sources with the defaults for the fields
+// that are not set.
+func (d DataSources) WithDefaults() DataSources {
+	def := DefaultDataSources()
+	if d.Workspace == nil {
+		d.Workspace = def.Workspace
+	}
+	if d.WorkspaceOwner == nil {
+		d.WorkspaceOwner = def.WorkspaceOwner
+	}
+	if d.Provisioner == nil {
+		d.Provisioner = def.Provisioner
+	}
+	return d
+}
+
+func (w WorkspaceData) values() map[string]cty.Value {
+	return map[string]cty.Value{
+		"id":               cty.StringVal(w.ID),
+		"name":             cty.StringVal(w.Name),
+		"owner":            cty.StringVal(""),
+		"start_count":      cty.NumberIntVal(int64(w.StartCount)),
+		"transition":       cty.StringVal(w.Transition),
+		"access_url":       cty.StringVal(w.AccessURL),
+		"access_port":      cty.NumberIntVal(int64(w.AccessPort)),
+		"template_id":      cty.StringVal(w.TemplateID),
+		"template_name":    cty.StringVal(w.TemplateName),
+		"template_version": cty.StringVal(w.TemplateVersion),
+		"is_prebuild":      cty.BoolVal(w.IsPrebuild),
+	}
+}
+
+func (o WorkspaceOwnerData) values() map[string]cty.Value {
+	groups := make([]cty.Value, 0, len(o.Groups))
+	for _, group := range o.Groups {
+		groups = append(groups, cty.StringVal(group))
+	}
+	groupsVal := cty.ListValEmpty(cty.String)
+	if len(groups) > 0 {
+		groupsVal = cty.ListVal(groups)
+	}
+
+	return map[string]cty.Value{
+		"id":             cty.StringVal(o.ID),
+		"name":           cty.StringVal(o.Name),
+		"full_name":      cty.StringVal(o.FullName),
+		"email":          cty.StringVal(o.Email),
+		"groups":         groupsVal,
+		"login_type":     cty.StringVal(o.LoginType),
+		"ssh_public_key": cty.StringVal(o.SSHPublicKey),
+	}
+}
+
+func (p ProvisionerData) values() map[string]cty.Value {
+	return map[string]cty.Value{
+		"id":   cty.StringVal(p.ID),
+		"os":   cty.StringVal(p.OS),
+		"arch": cty.StringVal(p.Arch),
+	}
+}
+
+// DataSourcesEvalHook sets the attributes of the coder data sources in the
+// evaluation context. Like ParameterContextsEvalHook, it runs on every
+// evaluation step, after the data blocks are evaluated.
+func DataSourcesEvalHook(input Input) func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+	sources := input.DataSources.WithDefaults()
+	values := map[string]map[string]cty.Value{
+		"coder_workspace":       sources.Workspace.values(),
+		"coder_workspace_owner": sources.WorkspaceOwner.values(),
+		"coder_provisioner":     sources.Provisioner.values(),
+	}
+
+	return func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+		for _, block := range blocks.OfType("data") {
+			attrs, ok := values[block.TypeLabel()]
+			if !ok || block.IsExpanded() {
+				continue
+			}
+
+			name := block.Reference().NameLabel()
+			for attr, val := range attrs {
+				if !block.GetAttribute(attr).IsNil() {
+					// Attributes set in the template win.
+					continue
+				}
+				ctx.Set(val, "data", block.TypeLabel(), name, attr)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_DataSources(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		data "coder_workspace" "me" {}
+		data "coder_workspace_owner" "me" {}
+		data "coder_provisioner" "me" {}
+
+		data "coder_parameter" "home" {
+			name    = "home"
+			default = "/home/${data.coder_workspace_owner.me.name}"
+		}
+
+		data "coder_workspace_tags" "tags" {
+			tags = {
+				"owner"   = data.coder_workspace_owner.me.name
+				"arch"    = data.coder_provisioner.me.arch
+				"running" = data.coder_workspace.me.start_count > 0 ? "yes" : "no"
+				"admin"   = contains(data.coder_workspace_owner.me.groups, "admins") ? "yes" : "no"
+				"home"    = data.coder_parameter.home.value
+			}
+		}`
+
+	for _, tc := range []struct {
+		name       string
+		input      coderism.Input
+		expectTags map[string]string
+	}{
+		{
+			name: "defaults",
+			expectTags: map[string]string{
+				"owner":   "default",
+				"arch":    "amd64",
+				"running": "yes",
+				"admin":   "no",
+				"home":    "/home/default",
+			},
+		},
+		{
+			name: "input",
+			input: coderism.Input{
+				DataSources: coderism.DataSources{
+					Workspace: &coderism.WorkspaceData{Name: "dev", StartCount: 0, Transition: "stop"},
+					WorkspaceOwner: &coderism.WorkspaceOwnerData{
+						Name:   "alice",
+						Groups: []string{"admins"},
+					},
+				},
+			},
+			expectTags: map[string]string{
+				"owner":   "alice",
+				"arch":    "amd64",
+				"running": "no",
+				"admin":   "yes",
+				"home":    "/home/alice",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), tc.input)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			tags, err := output.WorkspaceTags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectTags, tags)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package coderism
+
+import (
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Evaluate evaluates an expression in the root module of the template, with
+// its variables, locals, parameters and data sources. A reference to an
+// attribute that is not known during the preview returns an unknown value,
+// marked with the reason, rather than an error.
+func Evaluate(modules terraform.Modules, expr hcl.Expression) (cty.Value, hcl.Diagnostics) {
+	// The root module is the first module.
+	var blocks terraform.Blocks
+	if len(modules) > 0 {
+		blocks = modules[0].GetBlocks()
+	}
+	if len(blocks) == 0 {
+		r := expr.Range()
+		return cty.NilVal, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Empty template",
+			Detail:   "The template has no blocks, so there is nothing to evaluate the expression with.",
+			Subject:  &r,
+		}}
+	}
+	// Attributes computed by the provider are only in the context if the
+	// template references them, so the references of the expression are
+	// marked like UnknownsEvalHook marks the references of the template.
+	root := blocks[0].Context().Root().Inner()
+	evalCtx := &hcl.EvalContext{
+		Variables: make(map[string]cty.Value, len(root.Variables)),
+		Functions: root.Functions,
+	}
+	for name, val := range root.Variables {
+		evalCtx.Variables[name] = val
+	}
+	refs := make(map[string]map[string]bool)
+	for _, traversal := range expr.Variables() {
+		addReferencedAttribute(refs, traversal)
+	}
+	setUnknownAttributes(tfcontext.NewContext(evalCtx, nil), blocks, refs)
+
+	val, diags := expr.Value(evalCtx)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	if name, ok := impureCall(expr); ok {
+		// Like parameter defaults, the value is a new one on every call.
+		return cty.UnknownVal(val.Type()).Mark(UnknownMark{Reason: UnknownImpureFunction, Reference: name + "()"}), diags
+	}
+	return markUnknown(val, cty.DynamicPseudoType, expr), diags
+}
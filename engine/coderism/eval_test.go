@@ -0,0 +1,116 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		variable "registry" {
+			default = "docker.io"
+		}
+
+		data "coder_workspace_owner" "me" {}
+
+		data "coder_parameter" "image" {
+			name    = "image"
+			default = "ubuntu"
+		}
+
+		locals {
+			image = "${var.registry}/${data.coder_parameter.image.value}"
+		}
+
+		resource "docker_image" "main" {
+			name = local.image
+		}`
+
+	input := coderism.Input{
+		ParameterValues: []*proto.RichParameterValue{
+			{Name: "image", Value: "debian"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		expr          string
+		expectValue   cty.Value
+		expectUnknown coderism.UnknownReason
+		expectError   bool
+	}{
+		{
+			name:        "local",
+			expr:        "local.image",
+			expectValue: cty.StringVal("docker.io/debian"),
+		},
+		{
+			name:        "data source stub",
+			expr:        `upper(data.coder_workspace_owner.me.name)`,
+			expectValue: cty.StringVal("DEFAULT"),
+		},
+		{
+			name:        "configured attribute",
+			expr:        "docker_image.main.name",
+			expectValue: cty.StringVal("docker.io/debian"),
+		},
+		{
+			name:          "computed attribute",
+			expr:          "docker_image.main.repo_digest",
+			expectUnknown: coderism.UnknownResourceAttribute,
+		},
+		{
+			name:          "impure function",
+			expr:          "uuid()",
+			expectUnknown: coderism.UnknownImpureFunction,
+		},
+		{
+			name:        "undeclared",
+			expr:        "local.missing",
+			expectError: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+
+			_, modules, _, err := engine.ParseTerraform(context.Background(), input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			expr, diags := hclsyntax.ParseExpression([]byte(tc.expr), "expr", hcl.InitialPos)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			val, diags := coderism.Evaluate(modules, expr)
+			if tc.expectError {
+				require.True(t, diags.HasErrors())
+				return
+			}
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			if tc.expectUnknown != "" {
+				require.False(t, val.IsKnown())
+				marks := coderism.UnknownMarks(val)
+				require.Len(t, marks, 1)
+				require.Equal(t, tc.expectUnknown, marks[0].Reason)
+				return
+			}
+			require.True(t, tc.expectValue.RawEquals(val), "got %#v", val)
+		})
+	}
+}
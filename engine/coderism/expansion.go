@@ -0,0 +1,221 @@
+package coderism
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// parameterName is the name label of the coder_parameter block, without the
+// instance key of blocks expanded by count or for_each.
+func parameterName(block *terraform.Block) string {
+	return block.Reference().NameLabel()
+}
+
+// instanceKey returns the 'count.index' or 'each.key' of a block expanded by
+// count or for_each. Blocks that are not expanded return cty.NilVal.
+func instanceKey(block *terraform.Block) cty.Value {
+	if !block.IsExpanded() {
+		return cty.NilVal
+	}
+	return block.Reference().RawKey()
+}
+
+// setParameterValues sets the resolved values as the 'value' attribute of
+// each coder_parameter. Blocks expanded by count are set as a tuple, and
+// blocks expanded by for_each as an object, so references like
+// 'data.coder_parameter.name[0].value' resolve.
+func setParameterValues(ctx *tfcontext.Context, params terraform.Blocks, values map[*terraform.Block]cty.Value) {
+	expanded := make(map[string]terraform.Blocks)
+	for _, block := range params {
+		name := parameterName(block)
+		if !block.IsExpanded() {
+			ctx.Set(values[block], "data", "coder_parameter", name, "value")
+			continue
+		}
+		expanded[name] = append(expanded[name], block)
+	}
+
+	for name, blocks := range expanded {
+		ctx.Set(expandedParameterValue(blocks, values), "data", "coder_parameter", name)
+	}
+}
+
+func expandedParameterValue(blocks terraform.Blocks, values map[*terraform.Block]cty.Value) cty.Value {
+	return expandedBlocksValue(blocks, func(block *terraform.Block) map[string]cty.Value {
+		return map[string]cty.Value{"value": values[block]}
+	})
+}
+
+// expandedBlocksValue is the value of the instances of an expanded block, a
+// tuple for count and an object for for_each. Each instance has the
+// attributes of its block, and the attributes from 'override'.
+func expandedBlocksValue(blocks terraform.Blocks, override func(block *terraform.Block) map[string]cty.Value) cty.Value {
+	instances := make(map[string]cty.Value, len(blocks))
+	indexed := make([]cty.Value, len(blocks))
+	isCount := true
+	for _, block := range blocks {
+		attrs := block.Values().AsValueMap()
+		if attrs == nil {
+			attrs = make(map[string]cty.Value)
+		}
+		for name, val := range override(block) {
+			attrs[name] = val
+		}
+		instance := cty.ObjectVal(attrs)
+
+		key := instanceKey(block)
+		switch {
+		case key.Type() == cty.Number:
+			i, _ := key.AsBigFloat().Int64()
+			if i >= 0 && int(i) < len(indexed) {
+				indexed[i] = instance
+			}
+		case key.Type() == cty.String:
+			isCount = false
+			instances[key.AsString()] = instance
+		}
+	}
+
+	if isCount {
+		for i := range indexed {
+			if indexed[i] == cty.NilVal {
+				indexed[i] = cty.EmptyObjectVal
+			}
+		}
+		return cty.TupleVal(indexed)
+	}
+	return cty.ObjectVal(instances)
+}
+
+// expansionDiagnostics warns about coder_parameter blocks where the number
+// of instances is not known. An unknown count is expanded to a single
+// instance, and an unknown for_each drops the block entirely.
+func expansionDiagnostics(module *terraform.Module, files map[string]*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	params := module.GetDatasByType("coder_parameter")
+
+	warned := make(map[string]bool)
+	for _, block := range params {
+		countAttr := block.GetAttribute("count")
+		if countAttr.IsNil() || warned[parameterName(block)] {
+			continue
+		}
+
+		count, _ := countAttr.HCLAttribute().Expr.Value(block.Context().Inner())
+		if count.IsWhollyKnown() {
+			continue
+		}
+
+		warned[parameterName(block)] = true
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity:    hcl.DiagWarning,
+			Summary:     "Unknown parameter count",
+			Detail:      fmt.Sprintf("The count of parameter %q is not known during the preview. It is shown as a single instance.", parameterName(block)),
+			Subject:     &countAttr.HCLAttribute().Range,
+			Expression:  countAttr.HCLAttribute().Expr,
+			EvalContext: block.Context().Inner(),
+		})
+	}
+
+	return diags.Extend(unknownForEachDiagnostics(module, files, params))
+}
+
+// unknownForEachDiagnostics finds the coder_parameter blocks that were
+// dropped from the module because their for_each is not known. The module
+// no longer has these blocks, so they are found in the source files.
+func unknownForEachDiagnostics(module *terraform.Module, files map[string]*hcl.File, params terraform.Blocks) hcl.Diagnostics {
+	blocks := module.GetBlocks()
+	if len(blocks) == 0 {
+		return nil
+	}
+	evalCtx := blocks[0].Context().Root().Inner()
+
+	existing := make(map[string]bool)
+	for _, block := range params {
+		existing[parameterName(block)] = true
+	}
+
+	var diags hcl.Diagnostics
+	for _, block := range declaredBlocks(files, blocks) {
+		if block.Type != "data" || block.Labels[0] != "coder_parameter" {
+			continue
+		}
+
+		forEach, ok := metaArguments(block.Body)["for_each"]
+		if !ok || existing[block.Labels[1]] {
+			continue
+		}
+
+		val, _ := forEach.Expr.Value(evalCtx)
+		if val.IsWhollyKnown() {
+			// Known and empty, so there are no instances.
+			continue
+		}
+
+		r := forEach.Range
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity:    hcl.DiagWarning,
+			Summary:     "Unknown parameter for_each",
+			Detail:      fmt.Sprintf("The for_each of parameter %q is not known during the preview. It is not shown.", block.Labels[1]),
+			Subject:     &r,
+			Expression:  forEach.Expr,
+			EvalContext: evalCtx,
+		})
+	}
+	return diags
+}
+
+var declaredBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+	},
+}
+
+var metaArgumentSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "count"},
+		{Name: "for_each"},
+		{Name: "provider"},
+	},
+}
+
+// declaredBlocks returns the resource and data blocks as they are written in
+// the files the parser loaded for the module of the blocks. Unlike the
+// evaluated module, this includes the blocks dropped by a count of 0 or a
+// for_each that is not known.
+func declaredBlocks(files map[string]*hcl.File, blocks terraform.Blocks) []*hcl.Block {
+	// The files of a module are the files in its directory.
+	dirs := make(map[string]bool)
+	for _, block := range blocks {
+		dirs[path.Dir(block.HCLBlock().DefRange.Filename)] = true
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if dirs[path.Dir(name)] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var declared []*hcl.Block
+	for _, name := range names {
+		content, _, _ := files[name].Body.PartialContent(declaredBlockSchema)
+		declared = append(declared, content.Blocks...)
+	}
+	return declared
+}
+
+// metaArguments returns the count, for_each and provider attributes of the
+// body of a block.
+func metaArguments(body hcl.Body) hcl.Attributes {
+	content, _, _ := body.PartialContent(metaArgumentSchema)
+	return content.Attributes
+}
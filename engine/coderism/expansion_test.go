@@ -0,0 +1,135 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+func Test_ParameterExpansion(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		locals {
+			disks = ["home", "data"]
+		}
+		data "coder_parameter" "advanced" {
+			name    = "advanced"
+			type    = "bool"
+			default = false
+			mutable = true
+		}
+		data "coder_parameter" "cpu" {
+			count   = data.coder_parameter.advanced.value ? 1 : 0
+			name    = "cpu"
+			default = "2"
+		}
+		data "coder_parameter" "disk" {
+			for_each = toset(local.disks)
+			name     = "disk-${each.key}"
+			default  = "10"
+		}
+		data "coder_parameter" "unknown_count" {
+			count   = length(docker_image.main.repo_digest)
+			name    = "unknown_count"
+			default = "x"
+		}
+		data "coder_parameter" "unknown_for_each" {
+			for_each = toset(docker_image.main.repo_digest)
+			name     = "unknown_for_each"
+			default  = "x"
+		}
+		data "coder_workspace_tags" "tags" {
+			tags = {
+				"cpu" = data.coder_parameter.advanced.value ? data.coder_parameter.cpu[0].value : "none"
+			}
+		}`
+
+	// Blocks dropped from JSON files are found too.
+	const mainJSON = `{
+		"data": {
+			"coder_parameter": {
+				"json_for_each": {
+					"for_each": "${toset(docker_image.main.repo_digest)}",
+					"name": "json_for_each",
+					"default": "x"
+				}
+			}
+		}
+	}`
+
+	for _, tc := range []struct {
+		name       string
+		input      coderism.Input
+		expectKeys map[string]cty.Value
+		expectTags map[string]string
+	}{
+		{
+			name: "count is zero",
+			expectKeys: map[string]cty.Value{
+				"advanced":      cty.NilVal,
+				"disk-home":     cty.StringVal("home"),
+				"disk-data":     cty.StringVal("data"),
+				"unknown_count": cty.NumberIntVal(0),
+			},
+			expectTags: map[string]string{"cpu": "none"},
+		},
+		{
+			name: "count is one",
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "advanced", Value: "true"},
+					{Name: "cpu", Value: "4"},
+				},
+			},
+			expectKeys: map[string]cty.Value{
+				"advanced":      cty.NilVal,
+				"cpu":           cty.NumberIntVal(0),
+				"disk-home":     cty.StringVal("home"),
+				"disk-data":     cty.StringVal("data"),
+				"unknown_count": cty.NumberIntVal(0),
+			},
+			expectTags: map[string]string{"cpu": "4"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+			err = afero.WriteFile(memfs, "main.tf.json", []byte(mainJSON), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), tc.input)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			summaries := make([]string, 0)
+			for _, diag := range diags {
+				summaries = append(summaries, diag.Summary)
+			}
+			require.ElementsMatch(t, []string{"Unknown parameter count", "Unknown parameter for_each", "Unknown parameter for_each"}, summaries)
+
+			keys := make(map[string]cty.Value)
+			for _, param := range output.Parameters {
+				keys[param.Data.Name] = param.InstanceKey
+			}
+			require.Equal(t, tc.expectKeys, keys)
+
+			tags, err := output.WorkspaceTags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectTags, tags)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package coderism
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownStep is one reference in the chain from an unknown tag to the
+// first thing that is not known during the preview.
+type UnknownStep struct {
+	// Reference is the reference as written, like 'local.zone'.
+	Reference string
+	// Range is where the referenced thing is declared.
+	Range hcl.Range
+	// Reason and Detail explain why the value is not known. Only the last
+	// step has a reason.
+	Reason UnknownReason
+	Detail string
+}
+
+func (s UnknownStep) String() string {
+	str := fmt.Sprintf("%s (%s)", s.Reference, s.Range.String())
+	if s.Detail != "" {
+		str += ": " + s.Detail
+	}
+	return str
+}
+
+// ExplainUnknown follows the references of an unknown tag through locals,
+// parameters, variables, data sources and resources, until it finds the
+// thing that is not known. Known tags return nil.
+func (t TagBlock) ExplainUnknown(tag Tag) []UnknownStep {
+	expr := tag.valueExpr
+	if !isKnown(tag.key) {
+		expr = tag.keyExpr
+	} else if isKnown(tag.val) {
+		return nil
+	}
+	return explainUnknown(t.moduleBlocks(), expr, t.block.Context().Inner(), make(map[string]bool))
+}
+
+func explainUnknown(blocks terraform.Blocks, expr hcl.Expression, evalCtx *hcl.EvalContext, seen map[string]bool) []UnknownStep {
+	if expr == nil {
+		return nil
+	}
+
+	for _, traversal := range expr.Variables() {
+		val, diags := traversal.TraverseAbs(evalCtx)
+		if !diags.HasErrors() && isKnown(val) {
+			continue
+		}
+
+		ref := traversalString(traversal)
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		next := followReference(blocks, traversal)
+		step := UnknownStep{
+			Reference: ref,
+			Range:     next.declRange,
+			Reason:    next.reason,
+			Detail:    next.detail,
+		}
+		if next.expr == nil {
+			return []UnknownStep{step}
+		}
+
+		rest := explainUnknown(blocks, next.expr, next.evalCtx, seen)
+		if len(rest) == 0 {
+			// Nothing referenced is unknown, so the expression itself
+			// is, like a function that is not known during the preview.
+			if name, ok := impureCall(next.expr); ok {
+				step.Reason = UnknownImpureFunction
+				step.Detail = fmt.Sprintf("%q returns a different value every time, it is only known after apply.", name)
+			}
+			return []UnknownStep{step}
+		}
+		step.Reason, step.Detail = "", ""
+		return append([]UnknownStep{step}, rest...)
+	}
+	return nil
+}
+
+type referenceTarget struct {
+	// expr is the expression that produces the value, if there is one to
+	// follow.
+	expr      hcl.Expression
+	evalCtx   *hcl.EvalContext
+	declRange hcl.Range
+	reason    UnknownReason
+	detail    string
+}
+
+// followReference finds where a reference is declared. If the value comes
+// from another expression, it is returned to be explained further.
+func followReference(blocks terraform.Blocks, traversal hcl.Traversal) referenceTarget {
+	attrs := traversalAttrs(traversal)
+	target := referenceTarget{
+		declRange: traversal.SourceRange(),
+		reason:    UnknownOther,
+		detail:    "The value is not known during the preview.",
+	}
+	if len(attrs) < 2 {
+		return target
+	}
+
+	switch attrs[0] {
+	case "local":
+		for _, block := range blocks.OfType("locals") {
+			attr := block.GetAttribute(attrs[1])
+			if attr.IsNil() {
+				continue
+			}
+			target.expr = attr.HCLAttribute().Expr
+			target.evalCtx = block.Context().Inner()
+			target.declRange = attr.HCLAttribute().Range
+			return target
+		}
+		target.detail = "The local value is not declared."
+	case "var":
+		block := findBlock(blocks, "variable", "", attrs[1])
+		if block == nil {
+			target.detail = "The variable is not declared."
+			return target
+		}
+		target.declRange = block.HCLBlock().DefRange
+		target.detail = "The variable has no value."
+		if def := block.GetAttribute("default"); !def.IsNil() {
+			target.expr = def.HCLAttribute().Expr
+			target.evalCtx = block.Context().Inner()
+			target.detail = "The default of the variable is not known."
+		}
+	case "module":
+		if block := findBlock(blocks, "module", "", attrs[1]); block != nil {
+			target.declRange = block.HCLBlock().DefRange
+		}
+		target.detail = "Module outputs are not known during the preview."
+	case "data":
+		if len(attrs) < 3 {
+			return target
+		}
+		block := findBlock(blocks, "data", attrs[1], attrs[2])
+		if block == nil {
+			target.detail = "The data source is not declared."
+			return target
+		}
+		if attrs[1] == "coder_parameter" {
+			return parameterTarget(block, target)
+		}
+		target.reason = UnknownDataSource
+		target.detail = "is read by the provider, and is not known without a stub."
+		return attributeTarget(block, attrs[3:], target)
+	case "count", "each", "path", "self", "terraform":
+	default:
+		block := findBlock(blocks, "resource", attrs[0], attrs[1])
+		if block == nil {
+			target.detail = "The resource is not declared."
+			return target
+		}
+		target.reason = UnknownResourceAttribute
+		target.detail = "is computed by the provider, and is only known after apply."
+		return attributeTarget(block, attrs[2:], target)
+	}
+	return target
+}
+
+// parameterTarget explains the value of a coder_parameter, which is the
+// input value or the default.
+func parameterTarget(block *terraform.Block, target referenceTarget) referenceTarget {
+	target.declRange = block.HCLBlock().DefRange
+	def := block.GetAttribute("default")
+	if def.IsNil() {
+		target.reason = UnknownParameterValue
+		target.detail = "The parameter has no default, and no value is given."
+		return target
+	}
+	target.expr = def.HCLAttribute().Expr
+	target.evalCtx = block.Context().Inner()
+	target.detail = "The default of the parameter is not known."
+	return target
+}
+
+// attributeTarget explains an attribute of a data source or resource. An
+// attribute that is not set in the configuration comes from the provider,
+// and the target's reason and detail describe that.
+func attributeTarget(block *terraform.Block, attrs []string, target referenceTarget) referenceTarget {
+	target.declRange = block.HCLBlock().DefRange
+	if len(attrs) == 0 {
+		target.detail = fmt.Sprintf("%s %s", block.Reference().String(), target.detail)
+		return target
+	}
+
+	attr := block.GetAttribute(attrs[0])
+	if attr.IsNil() {
+		target.detail = fmt.Sprintf("%q %s", attrs[0], target.detail)
+		return target
+	}
+	target.expr = attr.HCLAttribute().Expr
+	target.evalCtx = block.Context().Inner()
+	target.declRange = attr.HCLAttribute().Range
+	target.reason = UnknownOther
+	target.detail = fmt.Sprintf("%q is not known during the preview.", attrs[0])
+	return target
+}
+
+func findBlock(blocks terraform.Blocks, blockType string, typeLabel string, name string) *terraform.Block {
+	for _, block := range blocks.OfType(blockType) {
+		labels := block.HCLBlock().Labels
+		switch {
+		case typeLabel == "" && len(labels) == 1 && labels[0] == name:
+			return block
+		case typeLabel != "" && len(labels) == 2 && labels[0] == typeLabel && labels[1] == name:
+			return block
+		}
+	}
+	return nil
+}
+
+// traversalAttrs returns the names in the traversal, without the indexes.
+func traversalAttrs(traversal hcl.Traversal) []string {
+	var names []string
+	for _, part := range traversal {
+		switch p := part.(type) {
+		case hcl.TraverseRoot:
+			names = append(names, p.Name)
+		case hcl.TraverseAttr:
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// traversalString formats a traversal like it is written. Unlike
+// hclext.ReferenceNames, indexes are not separated by dots.
+func traversalString(traversal hcl.Traversal) string {
+	var str strings.Builder
+	for _, part := range traversal {
+		switch p := part.(type) {
+		case hcl.TraverseRoot:
+			str.WriteString(p.Name)
+		case hcl.TraverseAttr:
+			str.WriteString("." + p.Name)
+		case hcl.TraverseIndex:
+			if p.Key.Type() == cty.String {
+				str.WriteString(fmt.Sprintf("[%q]", p.Key.AsString()))
+			} else if p.Key.Type() == cty.Number {
+				str.WriteString("[" + p.Key.AsBigFloat().String() + "]")
+			} else {
+				str.WriteString("[?]")
+			}
+		case hcl.TraverseSplat:
+			str.WriteString("[*]")
+		}
+	}
+	return str.String()
+}
+
+func (t TagBlock) moduleBlocks() terraform.Blocks {
+	if t.module == nil {
+		return nil
+	}
+	return t.module.GetBlocks()
+}
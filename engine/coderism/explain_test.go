@@ -0,0 +1,93 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_ExplainUnknown(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		main   string
+		expect []string
+	}{
+		{
+			name: "resource attribute",
+			main: `
+				resource "docker_image" "main" {
+					name = "ubuntu"
+				}
+				locals {
+					digest = docker_image.main.repo_digest
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"digest" = local.digest
+					}
+				}`,
+			expect: []string{
+				"local.digest (main.tf:6,6-44)",
+				`docker_image.main.repo_digest (main.tf:2,5-35): "repo_digest" is computed by the provider, and is only known after apply.`,
+			},
+		},
+		{
+			name: "parameter default",
+			main: `
+				resource "docker_image" "main" {
+					name = "ubuntu"
+				}
+				data "coder_parameter" "image" {
+					name    = "image"
+					default = docker_image.main.repo_digest
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"image" = data.coder_parameter.image.value
+					}
+				}`,
+			expect: []string{
+				"data.coder_parameter.image.value (main.tf:5,5-35)",
+				`docker_image.main.repo_digest (main.tf:2,5-35): "repo_digest" is computed by the provider, and is only known after apply.`,
+			},
+		},
+		{
+			name: "known",
+			main: `
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"zone" = "us"
+					}
+				}`,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			tags, _ := coderism.WorkspaceTags(modules)
+			require.Len(t, tags, 1)
+			require.Len(t, tags[0].Tags, 1)
+
+			var got []string
+			for _, step := range tags[0].ExplainUnknown(tags[0].Tags[0]) {
+				got = append(got, step.String())
+			}
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
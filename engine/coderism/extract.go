@@ -7,6 +7,7 @@ import (
 	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/convert"
 
@@ -16,10 +17,44 @@ import (
 
 type Input struct {
 	ParameterValues []*proto.RichParameterValue
+	// PreviousParameterValues are the values of the previous workspace build.
+	// Like a workspace update, a previous value is reused when no new value
+	// is given, unless the parameter is ephemeral.
+	PreviousParameterValues []*proto.RichParameterValue
+	// DataSources are the values of the coder data sources, like
+	// 'data.coder_workspace_owner.me.name'. Defaults are used when not set.
+	DataSources DataSources
+	// DataFixtures are the values of other data sources, which are not known
+	// without reading them from the provider.
+	DataFixtures []DataFixture
+	// State is the 'terraform show -json' state of an existing workspace.
+	// Its values are used for the attributes the template does not set.
+	State *tfjson.State
 }
 
 func (i Input) RichParameterValue(key string) (*proto.RichParameterValue, bool) {
-	for _, p := range i.ParameterValues {
+	return findParameterValue(i.ParameterValues, key)
+}
+
+func (i Input) PreviousRichParameterValue(key string) (*proto.RichParameterValue, bool) {
+	return findParameterValue(i.PreviousParameterValues, key)
+}
+
+// parameterValue returns the value to use for the coder_parameter block,
+// falling back to the previous build's value.
+func (i Input) parameterValue(block *terraform.Block) (*proto.RichParameterValue, bool) {
+	name := parameterName(block)
+	if pv, ok := i.RichParameterValue(name); ok {
+		return pv, true
+	}
+	if block.GetAttribute("ephemeral").IsTrue() {
+		return nil, false
+	}
+	return i.PreviousRichParameterValue(name)
+}
+
+func findParameterValue(values []*proto.RichParameterValue, key string) (*proto.RichParameterValue, bool) {
+	for _, p := range values {
 		if p.Name == key {
 			return p, true
 		}
@@ -30,23 +65,54 @@ func (i Input) RichParameterValue(key string) (*proto.RichParameterValue, bool)
 type Output struct {
 	WorkspaceTags TagBlocks
 	Parameters    []Parameter
+	Resources     []Resource
 }
 
-func Extract(modules terraform.Modules, input Input) (Output, hcl.Diagnostics) {
+// Extract reads the workspace tags, parameters and resources of the evaluated
+// modules. The files are the source files the parser loaded, like
+// parser.Files().
+func Extract(modules terraform.Modules, files map[string]*hcl.File, input Input) (Output, hcl.Diagnostics) {
 	//pcDiags := ParameterContexts(modules, input)
 	tags, tagDiags := WorkspaceTags(modules)
-	params, rpDiags := RichParameters(modules)
+	params, rpDiags := RichParameters(modules, files)
+	inputDiags := parameterInputDiagnostics(modules, input)
+	prevDiags := validatePreviousValues(params, input)
+	cycleDiags := ParameterCycles(modules)
+	fixtureDiags := dataFixtureDiagnostics(modules, input)
+	moduleDiags := moduleDiagnostics(modules)
 
 	return Output{
 		WorkspaceTags: tags,
 		Parameters:    params,
-	}, tagDiags.Extend(rpDiags)
+		Resources:     Resources(modules, files),
+	}, tagDiags.Extend(rpDiags).Extend(inputDiags).Extend(prevDiags).Extend(cycleDiags).Extend(fixtureDiags).Extend(moduleDiags)
+}
+
+// parameterInputDiagnostics reports input values that cannot be converted
+// to their parameter's type. The eval hook runs on every evaluation step, so
+// it cannot report these without duplicating them.
+func parameterInputDiagnostics(modules terraform.Modules, input Input) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, module := range modules {
+		for _, block := range module.GetDatasByType("coder_parameter") {
+			pv, ok := input.parameterValue(block)
+			if !ok {
+				continue
+			}
+			_, convDiags := parameterInputValue(block, pv)
+			diags = diags.Extend(convDiags)
+		}
+	}
+	return diags
 }
 
-func ParameterContextsEvalHook(input Input, diags hcl.Diagnostics) func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+// ParameterContextsEvalHook sets the values of the coder_parameter blocks.
+// The hook runs on every evaluation step, so it does not report diagnostics.
+// Input values that cannot be converted are reported by Extract.
+func ParameterContextsEvalHook(input Input) func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
 	return func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
-		data := blocks.OfType("data")
-		for _, block := range data {
+		var params terraform.Blocks
+		for _, block := range blocks.OfType("data") {
 			if block.TypeLabel() != "coder_parameter" {
 				continue
 			}
@@ -54,29 +120,22 @@ func ParameterContextsEvalHook(input Input, diags hcl.Diagnostics) func(ctx *tfc
 			if !block.GetAttribute("value").IsNil() {
 				continue // Wow a value exists?!. This feels like a bug.
 			}
+			params = append(params, block)
+		}
 
-			name := block.NameLabel()
-			var defDiags hcl.Diagnostics
+		// The current context is in the `coder_parameter` block.
+		// The values are set in the module context to "export" them.
+		resolveParameters(ctx, params, func(block *terraform.Block) cty.Value {
 			var value cty.Value
-			pv, ok := input.RichParameterValue(name)
+			pv, ok := input.parameterValue(block)
 			if ok {
-				// TODO: Handle non-string types
-				value = cty.StringVal(pv.Value)
+				value, _ = parameterInputValue(block, pv)
 			} else {
 				// get the default value
-				value, defDiags = evaluateCoderParameterDefault(block)
-				diags = diags.Extend(defDiags)
+				value, _ = evaluateCoderParameterDefault(block)
 			}
-
-			// Set the default value as the 'value' attribute
-			path := []string{"data"}
-			path = append(path, block.Labels()...)
-			path = append(path, "value")
-			// The current context is in the `coder_parameter` block.
-			// Use the parent context to "export" the value
-			ctx.Set(value, path...)
-			//block.Context().Parent().Set(value, path...)
-		}
+			return markParameterValue(block, value, ok)
+		})
 	}
 }
 
@@ -85,8 +144,8 @@ func ParameterContextsEvalHook(input Input, diags hcl.Diagnostics) func(ctx *tfc
 // of how 'default' value 'vars' are handled.
 //
 // Parameter values first come from the inputs, and then the 'defaults'.
-// TODO: This should be done in the evaluateStep in a loop, but that would
-// require forking. This might need to be done in a loop??
+// Prefer ParameterContextsEvalHook, which resolves parameters that depend on
+// other parameters during the evaluateStep.
 func ParameterContexts(modules terraform.Modules, input Input) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 	for _, module := range modules {
@@ -98,13 +157,12 @@ func ParameterContexts(modules terraform.Modules, input Input) hcl.Diagnostics {
 				continue
 			}
 
-			name := block.NameLabel()
 			var defDiags hcl.Diagnostics
 			var value cty.Value
-			pv, ok := input.RichParameterValue(name)
+			pv, ok := input.parameterValue(block)
 			if ok {
-				// TODO: Handle non-string types
-				value = cty.StringVal(pv.Value)
+				value, defDiags = parameterInputValue(block, pv)
+				diags = diags.Extend(defDiags)
 			} else {
 				// get the default value
 				value, defDiags = evaluateCoderParameterDefault(block)
@@ -157,30 +215,9 @@ func evaluateCoderParameterDefault(b *terraform.Block) (cty.Value, hcl.Diagnosti
 		}
 	}
 
-	var valType cty.Type
-	var defaults *typeexpr.Defaults
-	// TODO: `"string"` fails, it should be `string`
-	typeAttr, exists := attributes["type"]
-	if exists {
-		ty, def, err := hclext.DecodeVarType(typeAttr.HCLAttribute().Expr)
-		if err != nil {
-			return cty.NilVal, hcl.Diagnostics{
-				{
-					Severity:    hcl.DiagWarning,
-					Summary:     fmt.Sprintf("Decoding parameter type for %q", b.FullName()),
-					Detail:      err.Error(),
-					Subject:     &typeAttr.HCLAttribute().Range,
-					Context:     &b.HCLBlock().DefRange,
-					Expression:  typeAttr.HCLAttribute().Expr,
-					EvalContext: b.Context().Inner(),
-				},
-			}
-		}
-		valType = ty
-		defaults = def
-	} else {
-		// Default to string type
-		valType = cty.String
+	valType, defaults, typeDiags := coderParameterType(b)
+	if len(typeDiags) > 0 {
+		return cty.NilVal, typeDiags
 	}
 
 	var val cty.Value
@@ -197,7 +234,14 @@ func evaluateCoderParameterDefault(b *terraform.Block) (cty.Value, hcl.Diagnosti
 			val = defaults.Apply(val)
 		}
 
-		typedVal, err := convert.Convert(val, valType)
+		var typedVal cty.Value
+		var err error
+		if !valType.IsPrimitiveType() && val.Type() == cty.String && val.IsWhollyKnown() {
+			// Coder encodes non-primitive defaults as JSON strings.
+			typedVal, err = hclext.ParseValue(valType, val.AsString())
+		} else {
+			typedVal, err = convert.Convert(val, valType)
+		}
 		if err != nil {
 			return cty.NilVal, hcl.Diagnostics{
 				{
@@ -217,3 +261,51 @@ func evaluateCoderParameterDefault(b *terraform.Block) (cty.Value, hcl.Diagnosti
 	return val, nil
 
 }
+
+// coderParameterType decodes the 'type' attribute of a coder_parameter.
+// Parameters without a type are strings.
+func coderParameterType(b *terraform.Block) (cty.Type, *typeexpr.Defaults, hcl.Diagnostics) {
+	typeAttr := b.GetAttribute("type")
+	if typeAttr.IsNil() {
+		return cty.String, nil, nil
+	}
+
+	ty, def, err := hclext.DecodeVarType(typeAttr.HCLAttribute().Expr)
+	if err != nil {
+		return cty.NilType, nil, hcl.Diagnostics{
+			{
+				Severity:    hcl.DiagWarning,
+				Summary:     fmt.Sprintf("Decoding parameter type for %q", b.FullName()),
+				Detail:      err.Error(),
+				Subject:     &typeAttr.HCLAttribute().Range,
+				Context:     &b.HCLBlock().DefRange,
+				Expression:  typeAttr.HCLAttribute().Expr,
+				EvalContext: b.Context().Inner(),
+			},
+		}
+	}
+	return ty, def, nil
+}
+
+// parameterInputValue converts the raw input value of a parameter to the
+// type declared by the coder_parameter block.
+func parameterInputValue(b *terraform.Block, pv *proto.RichParameterValue) (cty.Value, hcl.Diagnostics) {
+	ty, _, diags := coderParameterType(b)
+	if ty == cty.NilType {
+		// Unknown type, fallback to the raw string.
+		return cty.StringVal(pv.Value), diags
+	}
+
+	val, err := hclext.ParseValue(ty, pv.Value)
+	if err != nil {
+		r := b.HCLBlock().DefRange
+		return cty.UnknownVal(ty), diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid parameter value",
+			Detail: fmt.Sprintf("The value %q for parameter %q cannot be converted to type %s: %s",
+				pv.Value, pv.Name, ty.FriendlyName(), err.Error()),
+			Subject: &r,
+		})
+	}
+	return val, diags
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
+	protobuf "google.golang.org/protobuf/proto"
 
 	"github.com/coder/terraform-eval/engine"
 	"github.com/coder/terraform-eval/engine/coderism"
@@ -123,6 +124,75 @@ func Test_Extract(t *testing.T) {
 			input:  coderism.Input{},
 			params: map[string]func(t *testing.T, parameter coderism.Parameter){},
 		},
+		{
+			name:        "parameter fields",
+			dir:         "paramfields",
+			expTags:     map[string]string{},
+			expUnknowns: []string{},
+			params: map[string]func(t *testing.T, parameter coderism.Parameter){
+				"cpu": ap[cty.Value]().
+					value(cty.MustParseNumberVal("4")).
+					data(&proto.RichParameter{
+						Name:                "cpu",
+						Description:         "Number of cores",
+						Type:                "number",
+						Mutable:             true,
+						DefaultValue:        "4",
+						Icon:                "/icon/cpu.svg",
+						ValidationError:     "Between 2 and 8 cores",
+						ValidationMin:       ptr[int32](2),
+						ValidationMax:       ptr[int32](8),
+						ValidationMonotonic: "increasing",
+						DisplayName:         "CPU Cores",
+						Order:               2,
+					}).f(),
+				"name": ap[cty.Value]().
+					data(&proto.RichParameter{
+						Name:            "name",
+						Type:            "string",
+						ValidationRegex: "^[a-z]+$",
+						ValidationError: "Lowercase letters only",
+						Required:        true,
+						Order:           1,
+						Ephemeral:       true,
+					}).f(),
+			},
+		},
+		{
+			name: "typed parameter values",
+			dir:  "typedparams",
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "gpu", Value: "true"},
+					{Name: "cores", Value: "8"},
+					{Name: "regions", Value: `["eu","us"]`},
+				},
+			},
+			expTags: map[string]string{
+				"gpu":    "yes",
+				"large":  "yes",
+				"region": "eu",
+			},
+			params: map[string]func(t *testing.T, parameter coderism.Parameter){
+				"gpu":     ap[cty.Value]().value(cty.True).f(),
+				"cores":   ap[cty.Value]().value(cty.MustParseNumberVal("8")).f(),
+				"regions": ap[cty.Value]().value(cty.ListVal([]cty.Value{cty.StringVal("eu"), cty.StringVal("us")})).f(),
+			},
+		},
+		{
+			name: "typed parameter defaults",
+			dir:  "typedparams",
+			expTags: map[string]string{
+				"gpu":    "no",
+				"large":  "no",
+				"region": "us",
+			},
+			params: map[string]func(t *testing.T, parameter coderism.Parameter){
+				"gpu":     ap[cty.Value]().value(cty.False).f(),
+				"cores":   ap[cty.Value]().value(cty.MustParseNumberVal("2")).f(),
+				"regions": ap[cty.Value]().value(cty.ListVal([]cty.Value{cty.StringVal("us")})).f(),
+			},
+		},
 		{
 			name:     "external docker resource",
 			dir:      "dockerdata",
@@ -149,15 +219,15 @@ func Test_Extract(t *testing.T) {
 			dirFs, err := fs.Sub(testdata, filepath.Join("testdata", tc.dir))
 			require.NoError(t, err)
 
-			_, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, dirFs)
-			require.NoError(t, err)
-
 			if tc.showJSON != "" {
-				err = engine.ParseTFShow(dirFs, tc.showJSON)
+				tc.input.State, err = engine.ParseTFShow(dirFs, tc.showJSON)
 				require.NoError(t, err)
 			}
 
-			output, diags := coderism.Extract(modules, tc.input)
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, dirFs)
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), tc.input)
 			assert.False(t, diags.HasErrors())
 			if diags.HasErrors() {
 				t.Log(diags.Error())
@@ -213,3 +283,19 @@ func (a *assertParam[T]) value(v T) *assertParam[T] {
 	})
 	return &x
 }
+
+// data asserts every field of the RichParameter, except for the options.
+func (a *assertParam[T]) data(exp *proto.RichParameter) *assertParam[T] {
+	cpy := *a
+	x := assertParam[T](func(t *testing.T, parameter coderism.Parameter) {
+		got := protobuf.Clone(parameter.Data).(*proto.RichParameter)
+		got.Options = nil
+		assert.Truef(t, protobuf.Equal(exp, got), "param %q: expected %s, got %s", parameter.Data.Name, exp, got)
+		cpy(t, parameter)
+	})
+	return &x
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
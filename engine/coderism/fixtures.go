@@ -0,0 +1,242 @@
+package coderism
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DataFixture is the value of a data source, used instead of reading it from
+// the provider. Without fixtures, the attributes of data sources are not
+// known during the preview.
+type DataFixture struct {
+	Type string
+	Name string
+	// InstanceKey selects one instance of a data block expanded by count or
+	// for_each. cty.NilVal matches every instance.
+	InstanceKey cty.Value
+	Values      map[string]cty.Value
+	// Range is where the fixture is declared.
+	Range hcl.Range
+}
+
+// Address is the address of the data source, like 'data.aws_ami.ubuntu[0]'.
+func (f DataFixture) Address() string {
+	addr := fmt.Sprintf("data.%s.%s", f.Type, f.Name)
+	if key := instanceKeyString(f.InstanceKey); key != "" {
+		addr += "[" + key + "]"
+	}
+	return addr
+}
+
+func (f DataFixture) matches(block *terraform.Block) bool {
+	if block.Type() != "data" || block.TypeLabel() != f.Type || block.Reference().NameLabel() != f.Name {
+		return false
+	}
+	if f.InstanceKey == cty.NilVal {
+		return true
+	}
+	key := instanceKey(block)
+	return key != cty.NilVal && key.Type() == f.InstanceKey.Type() && key.Equals(f.InstanceKey).True()
+}
+
+// ParseDataFixtures parses a fixtures file. Files ending in '.json' are
+// JSON, anything else is HCL. Each data block sets the attributes of the
+// data source with the same type and name. A name with an index, like
+// 'ubuntu[0]' or 'ns["dev"]', only sets that instance.
+//
+//	data "aws_ami" "ubuntu" {
+//	  id = "ami-0123456789"
+//	}
+//
+// The same fixture in JSON is {"data": {"aws_ami": {"ubuntu": {"id": "ami-0123456789"}}}}.
+func ParseDataFixtures(filename string, src []byte) ([]DataFixture, hcl.Diagnostics) {
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(filename, ".json") {
+		file, diags = hcljson.Parse(src, filename)
+	} else {
+		file, diags = hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	content, contentDiags := file.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "data", LabelNames: []string{"type", "name"}},
+		},
+	})
+	diags = diags.Extend(contentDiags)
+
+	var fixtures []DataFixture
+	seen := make(map[string]hcl.Range)
+	for _, block := range content.Blocks {
+		name, key, nameDiags := parseFixtureName(block.Labels[1], block.LabelRanges[1])
+		diags = diags.Extend(nameDiags)
+		if nameDiags.HasErrors() {
+			continue
+		}
+
+		attrs, attrDiags := block.Body.JustAttributes()
+		diags = diags.Extend(attrDiags)
+
+		fixture := DataFixture{
+			Type:        block.Labels[0],
+			Name:        name,
+			InstanceKey: key,
+			Values:      make(map[string]cty.Value, len(attrs)),
+			Range:       block.DefRange,
+		}
+		for attrName, attr := range attrs {
+			val, valDiags := attr.Expr.Value(nil)
+			diags = diags.Extend(valDiags)
+			if valDiags.HasErrors() {
+				continue
+			}
+			fixture.Values[attrName] = val
+		}
+
+		if prev, ok := seen[fixture.Address()]; ok {
+			r := block.DefRange
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate data fixture",
+				Detail:   fmt.Sprintf("The fixture for %s is already declared at %s.", fixture.Address(), prev.String()),
+				Subject:  &r,
+			})
+			continue
+		}
+		seen[fixture.Address()] = block.DefRange
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, diags
+}
+
+// parseFixtureName splits a name label like 'ns["dev"]' into the name and
+// the instance key.
+func parseFixtureName(label string, r hcl.Range) (string, cty.Value, hcl.Diagnostics) {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(label), r.Filename, r.Start)
+	if diags.HasErrors() {
+		return "", cty.NilVal, diags
+	}
+
+	name := traversal.RootName()
+	switch len(traversal) {
+	case 1:
+		return name, cty.NilVal, nil
+	case 2:
+		if index, ok := traversal[1].(hcl.TraverseIndex); ok &&
+			(index.Key.Type() == cty.String || index.Key.Type() == cty.Number) {
+			return name, index.Key, nil
+		}
+	}
+	return "", cty.NilVal, hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid data fixture name",
+		Detail:   fmt.Sprintf("The name %q must be a name, optionally followed by a count index or for_each key, like 'name[0]'.", label),
+		Subject:  &r,
+	}}
+}
+
+// DataFixturesEvalHook sets the attributes of the data sources that have a
+// fixture in the evaluation context. Data blocks expanded by count or
+// for_each are set as a tuple or object of their instances, so references
+// like 'data.aws_ami.ubuntu[0].id' resolve.
+func DataFixturesEvalHook(input Input) func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+	return func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+		if len(input.DataFixtures) == 0 {
+			return
+		}
+
+		expanded := make(map[[2]string]terraform.Blocks)
+		for _, block := range blocks.OfType("data") {
+			if block.TypeLabel() == "coder_parameter" {
+				continue
+			}
+
+			name := block.Reference().NameLabel()
+			if block.IsExpanded() {
+				k := [2]string{block.TypeLabel(), name}
+				expanded[k] = append(expanded[k], block)
+				continue
+			}
+
+			for attr, val := range fixtureValues(input.DataFixtures, block) {
+				ctx.Set(val, "data", block.TypeLabel(), name, attr)
+			}
+		}
+
+		for k, instances := range expanded {
+			if !hasFixture(input.DataFixtures, instances) {
+				continue
+			}
+			ctx.Set(expandedBlocksValue(instances, func(block *terraform.Block) map[string]cty.Value {
+				return fixtureValues(input.DataFixtures, block)
+			}), "data", k[0], k[1])
+		}
+	}
+}
+
+// fixtureValues merges the fixtures that match the block. A fixture for the
+// instance wins over a fixture for every instance.
+func fixtureValues(fixtures []DataFixture, block *terraform.Block) map[string]cty.Value {
+	values := make(map[string]cty.Value)
+	for _, withKey := range []bool{false, true} {
+		for _, fixture := range fixtures {
+			if (fixture.InstanceKey != cty.NilVal) != withKey || !fixture.matches(block) {
+				continue
+			}
+			for attr, val := range fixture.Values {
+				values[attr] = val
+			}
+		}
+	}
+	return values
+}
+
+func hasFixture(fixtures []DataFixture, blocks terraform.Blocks) bool {
+	for _, block := range blocks {
+		for _, fixture := range fixtures {
+			if fixture.matches(block) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dataFixtureDiagnostics warns about fixtures that do not match any data
+// block, which usually is a typo in the type, name or instance key.
+func dataFixtureDiagnostics(modules terraform.Modules, input Input) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, fixture := range input.DataFixtures {
+		used := false
+		for _, module := range modules {
+			for _, block := range module.GetBlocks().OfType("data") {
+				if fixture.matches(block) {
+					used = true
+					break
+				}
+			}
+		}
+		if used {
+			continue
+		}
+
+		r := fixture.Range
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused data fixture",
+			Detail:   fmt.Sprintf("No data block matches %s, the fixture is not used.", fixture.Address()),
+			Subject:  &r,
+		})
+	}
+	return diags
+}
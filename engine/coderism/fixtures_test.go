@@ -0,0 +1,184 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_DataFixtures(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		data "aws_ami" "ubuntu" {
+			most_recent = true
+		}
+
+		data "aws_region" "zones" {
+			count = 2
+			name  = "zone-${count.index}"
+		}
+
+		data "vault_secret" "env" {
+			for_each = toset(["dev", "prod"])
+			path     = each.key
+		}
+
+		data "coder_workspace_tags" "tags" {
+			tags = {
+				"ami"    = data.aws_ami.ubuntu.id
+				"zone"   = data.aws_region.zones[1].id
+				"region" = data.aws_region.zones[0].name
+				"secret" = data.vault_secret.env["prod"].value
+			}
+		}`
+
+	for _, tc := range []struct {
+		name         string
+		filename     string
+		fixtures     string
+		expectTags   map[string]string
+		expectUnused []string
+	}{
+		{
+			name:     "hcl",
+			filename: "fixtures.hcl",
+			fixtures: `
+				data "aws_ami" "ubuntu" {
+					id = "ami-123"
+				}
+				data "aws_region" "zones" {
+					id = "any"
+				}
+				data "aws_region" "zones[1]" {
+					id = "us-east-1b"
+				}
+				data "vault_secret" "env[\"prod\"]" {
+					value = "hunter2"
+				}`,
+			expectTags: map[string]string{
+				"ami":    "ami-123",
+				"zone":   "us-east-1b",
+				"region": "zone-0",
+				"secret": "hunter2",
+			},
+		},
+		{
+			name:     "json",
+			filename: "fixtures.json",
+			fixtures: `{
+				"data": {
+					"aws_ami": {"ubuntu": {"id": "ami-456"}},
+					"aws_region": {"zones": {"id": "us-west-2a"}},
+					"vault_secret": {"env": {"value": "s3cret"}}
+				}
+			}`,
+			expectTags: map[string]string{
+				"ami":    "ami-456",
+				"zone":   "us-west-2a",
+				"region": "zone-0",
+				"secret": "s3cret",
+			},
+		},
+		{
+			name:     "unused",
+			filename: "fixtures.hcl",
+			fixtures: `
+				data "aws_ami" "ubuntu" {
+					id = "ami-123"
+				}
+				data "aws_region" "zones" {
+					id = "any"
+				}
+				data "vault_secret" "env" {
+					value = "hunter2"
+				}
+				data "aws_ami" "debian" {
+					id = "ami-789"
+				}
+				data "aws_region" "zones[5]" {
+					id = "nowhere"
+				}`,
+			expectTags: map[string]string{
+				"ami":    "ami-123",
+				"zone":   "any",
+				"region": "zone-0",
+				"secret": "hunter2",
+			},
+			expectUnused: []string{"data.aws_ami.debian", "data.aws_region.zones[5]"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fixtures, diags := coderism.ParseDataFixtures(tc.filename, []byte(tc.fixtures))
+			require.False(t, diags.HasErrors(), diags.Error())
+			input := coderism.Input{DataFixtures: fixtures}
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), input)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			tags, err := output.WorkspaceTags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectTags, tags)
+
+			var unused []string
+			for _, diag := range diags {
+				if diag.Summary == "Unused data fixture" {
+					require.Equal(t, hcl.DiagWarning, diag.Severity)
+					unused = append(unused, diag.Detail)
+				}
+			}
+			require.Len(t, unused, len(tc.expectUnused))
+			for i, addr := range tc.expectUnused {
+				require.Contains(t, unused[i], addr)
+			}
+		})
+	}
+}
+
+func Test_ParseDataFixturesErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		fixtures string
+		summary  string
+	}{
+		{
+			name: "duplicate",
+			fixtures: `
+				data "aws_ami" "ubuntu" {}
+				data "aws_ami" "ubuntu" {}`,
+			summary: "Duplicate data fixture",
+		},
+		{
+			name:     "invalid name",
+			fixtures: `data "aws_ami" "ubuntu.id" {}`,
+			summary:  "Invalid data fixture name",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, diags := coderism.ParseDataFixtures("fixtures.hcl", []byte(tc.fixtures))
+			require.True(t, diags.HasErrors())
+			require.Equal(t, tc.summary, diags[0].Summary)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package coderism
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+)
+
+type GraphNodeKind string
+
+const (
+	GraphNodeParameter GraphNodeKind = "parameter"
+	GraphNodeVariable  GraphNodeKind = "variable"
+	GraphNodeLocal     GraphNodeKind = "local"
+	GraphNodeData      GraphNodeKind = "data"
+	GraphNodeResource  GraphNodeKind = "resource"
+	GraphNodeModule    GraphNodeKind = "module"
+)
+
+// ParameterGraph is the graph of what each coder_parameter depends on.
+// Edges point from a parameter to the thing it references.
+type ParameterGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	// ID is the terraform address, like 'data.coder_parameter.region' or
+	// 'var.image'. Addresses in a child module start with the module, like
+	// 'module.ide.var.image'.
+	ID   string        `json:"id"`
+	Kind GraphNodeKind `json:"kind"`
+	// Module is the address of the module of the node, or empty for the
+	// root module.
+	Module string `json:"module"`
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ParameterDependencies builds the graph of the direct references of every
+// coder_parameter block. This includes the attributes, the option blocks and
+// meta arguments like count.
+func ParameterDependencies(modules terraform.Modules) ParameterGraph {
+	nodes := make(map[string]GraphNode)
+	edges := make(map[GraphEdge]bool)
+
+	for _, module := range modules {
+		for _, block := range module.GetDatasByType("coder_parameter") {
+			// References are local to the module of the block, so
+			// parameters with the same name in different modules are
+			// different nodes.
+			address := moduleAddress(block)
+			from := moduleNodeID(address, "data.coder_parameter."+parameterName(block))
+			nodes[from] = GraphNode{ID: from, Kind: GraphNodeParameter, Module: address}
+
+			for _, ref := range blockReferences(block) {
+				to, kind, ok := referenceNode(ref)
+				if !ok {
+					continue
+				}
+				to = moduleNodeID(address, to)
+				if to == from {
+					continue
+				}
+				if _, exists := nodes[to]; !exists {
+					nodes[to] = GraphNode{ID: to, Kind: kind, Module: address}
+				}
+				edges[GraphEdge{From: from, To: to}] = true
+			}
+		}
+	}
+
+	graph := ParameterGraph{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Edges: make([]GraphEdge, 0, len(edges)),
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	// Sort to keep the output deterministic.
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph
+}
+
+// DOT returns the graph in the graphviz DOT language.
+func (g ParameterGraph) DOT() string {
+	var str strings.Builder
+	str.WriteString("digraph parameters {\n")
+	str.WriteString("  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		shape := "ellipse"
+		if node.Kind == GraphNodeParameter {
+			shape = "box"
+		}
+		str.WriteString(fmt.Sprintf("  %q [shape=%s];\n", node.ID, shape))
+	}
+	for _, edge := range g.Edges {
+		str.WriteString(fmt.Sprintf("  %q -> %q;\n", edge.From, edge.To))
+	}
+	str.WriteString("}\n")
+	return str.String()
+}
+
+// moduleNodeID prefixes the address with the address of its module.
+func moduleNodeID(module, address string) string {
+	if module == "" {
+		return address
+	}
+	return module + "." + address
+}
+
+// referenceNode converts a reference, like 'data.coder_parameter.region.value',
+// to the address of the block it refers to. References to things that are
+// not blocks, like 'count.index' or 'path.module', are ignored.
+func referenceNode(ref string) (string, GraphNodeKind, bool) {
+	parts := strings.Split(ref, ".")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+
+	switch parts[0] {
+	case "var":
+		return strings.Join(parts[:2], "."), GraphNodeVariable, true
+	case "local":
+		return strings.Join(parts[:2], "."), GraphNodeLocal, true
+	case "module":
+		return strings.Join(parts[:2], "."), GraphNodeModule, true
+	case "data":
+		if len(parts) < 3 {
+			return "", "", false
+		}
+		id := strings.Join(parts[:3], ".")
+		if parts[1] == "coder_parameter" {
+			return id, GraphNodeParameter, true
+		}
+		return id, GraphNodeData, true
+	case "count", "each", "path", "terraform", "self":
+		return "", "", false
+	default:
+		return strings.Join(parts[:2], "."), GraphNodeResource, true
+	}
+}
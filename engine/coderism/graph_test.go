@@ -0,0 +1,76 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_ParameterDependencies(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(`
+		variable "regions" {
+			type    = set(string)
+			default = ["us", "eu"]
+		}
+		locals {
+			advanced = true
+		}
+		data "coder_parameter" "region" {
+			name    = "region"
+			default = "us"
+			dynamic "option" {
+				for_each = var.regions
+				content {
+					name  = option.value
+					value = option.value
+				}
+			}
+		}
+		data "coder_parameter" "zone" {
+			name    = "zone"
+			count   = local.advanced ? 1 : 0
+			default = "${data.coder_parameter.region.value}-a"
+		}
+		module "app" {
+			source = "./modules/app"
+		}`), 0644)
+	require.NoError(t, err)
+	// The module has a parameter with the same name, which is a different
+	// node.
+	err = afero.WriteFile(memfs, "modules/app/main.tf", []byte(`
+		variable "region" {
+			default = "eu"
+		}
+		data "coder_parameter" "region" {
+			name    = "app_region"
+			default = var.region
+		}`), 0644)
+	require.NoError(t, err)
+
+	_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+	require.NoError(t, err)
+
+	graph := coderism.ParameterDependencies(modules)
+	require.Equal(t, []coderism.GraphNode{
+		{ID: "data.coder_parameter.region", Kind: coderism.GraphNodeParameter},
+		{ID: "data.coder_parameter.zone", Kind: coderism.GraphNodeParameter},
+		{ID: "local.advanced", Kind: coderism.GraphNodeLocal},
+		{ID: "module.app.data.coder_parameter.region", Kind: coderism.GraphNodeParameter, Module: "module.app"},
+		{ID: "module.app.var.region", Kind: coderism.GraphNodeVariable, Module: "module.app"},
+		{ID: "var.regions", Kind: coderism.GraphNodeVariable},
+	}, graph.Nodes)
+	require.Equal(t, []coderism.GraphEdge{
+		{From: "data.coder_parameter.region", To: "var.regions"},
+		{From: "data.coder_parameter.zone", To: "data.coder_parameter.region"},
+		{From: "data.coder_parameter.zone", To: "local.advanced"},
+		{From: "module.app.data.coder_parameter.region", To: "module.app.var.region"},
+	}, graph.Edges)
+}
@@ -0,0 +1,174 @@
+package coderism
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ProvisionerDaemon is a provisioner daemon and the tags it was started
+// with.
+type ProvisionerDaemon struct {
+	Name string            `json:"name"`
+	Tags map[string]string `json:"tags"`
+}
+
+// The tags coderd gives every job and daemon. A job or daemon without a
+// scope is scoped to the organization. The owner is the user of a user
+// scoped job or daemon, and empty otherwise.
+const (
+	TagScope          = "scope"
+	TagOwner          = "owner"
+	ScopeOrganization = "organization"
+	ScopeUser         = "user"
+)
+
+type MatchStatus string
+
+const (
+	// MatchAccepted means the daemon has every tag of the job.
+	MatchAccepted MatchStatus = "accepted"
+	// MatchExcluded means the daemon can never pick up the job.
+	MatchExcluded MatchStatus = "excluded"
+	// MatchUnknown means the daemon has every known tag, but some tags are
+	// not known until the workspace is built.
+	MatchUnknown MatchStatus = "unknown"
+)
+
+type MismatchReason string
+
+const (
+	MismatchMissingKey     MismatchReason = "missing_key"
+	MismatchDifferentValue MismatchReason = "different_value"
+	MismatchUnknownTag     MismatchReason = "unknown_tag"
+)
+
+// TagMismatch is a job tag that the daemon does not satisfy.
+type TagMismatch struct {
+	Key    string         `json:"key"`
+	Reason MismatchReason `json:"reason"`
+	// Value is the value of the job tag, and DaemonValue is the value of
+	// the daemon tag. Both are empty when not applicable.
+	Value       string `json:"value,omitempty"`
+	DaemonValue string `json:"daemon_value,omitempty"`
+}
+
+func (m TagMismatch) String() string {
+	switch m.Reason {
+	case MismatchMissingKey:
+		return fmt.Sprintf("missing tag %q", m.Key)
+	case MismatchDifferentValue:
+		return fmt.Sprintf("tag %q is %q, not %q", m.Key, m.DaemonValue, m.Value)
+	case MismatchUnknownTag:
+		return fmt.Sprintf("tag %q is not known", m.Key)
+	}
+	return fmt.Sprintf("tag %q: %s", m.Key, m.Reason)
+}
+
+type ProvisionerMatch struct {
+	Daemon     ProvisionerDaemon `json:"daemon"`
+	Status     MatchStatus       `json:"status"`
+	Mismatches []TagMismatch     `json:"mismatches"`
+}
+
+// MatchProvisioners reports which daemons would accept a job with the tags,
+// for a workspace of the owner with the ID. Like coderd, a daemon accepts a
+// job if it has every tag of the job with the same value. Extra daemon tags
+// are ignored. The scope and owner tags coderd adds are added to both the job
+// and the daemons first, so the daemons of the match have them.
+func (t TagBlocks) MatchProvisioners(daemons []ProvisionerDaemon, ownerID string) ([]ProvisionerMatch, error) {
+	tags, err := t.ValidTags()
+	if err != nil {
+		return nil, err
+	}
+	var unknowns []Tag
+	for _, block := range t {
+		for _, tag := range block.Tags {
+			if !tag.IsKnown() {
+				unknowns = append(unknowns, tag)
+			}
+		}
+	}
+	if !hasUnknownKey(unknowns, TagScope) {
+		tags = implicitTags(tags, ownerID)
+	}
+
+	// Sort to keep the output deterministic.
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	matches := make([]ProvisionerMatch, 0, len(daemons))
+	for _, daemon := range daemons {
+		// The owner of a user scoped daemon is the user that started it,
+		// which is the owner tag coderd reports for it.
+		daemon.Tags = implicitTags(daemon.Tags, daemon.Tags[TagOwner])
+		match := ProvisionerMatch{
+			Daemon:     daemon,
+			Status:     MatchAccepted,
+			Mismatches: []TagMismatch{},
+		}
+		for _, k := range keys {
+			dv, ok := daemon.Tags[k]
+			switch {
+			case !ok:
+				match.Mismatches = append(match.Mismatches, TagMismatch{Key: k, Reason: MismatchMissingKey, Value: tags[k]})
+			case dv != tags[k]:
+				match.Mismatches = append(match.Mismatches, TagMismatch{Key: k, Reason: MismatchDifferentValue, Value: tags[k], DaemonValue: dv})
+			}
+		}
+		if len(match.Mismatches) > 0 {
+			match.Status = MatchExcluded
+		}
+
+		for _, tag := range unknowns {
+			k := tag.SafeKeyString()
+			dv, ok := daemon.Tags[k]
+			if isKnown(tag.key) && !ok {
+				// The value is not known, but the key is still required.
+				match.Mismatches = append(match.Mismatches, TagMismatch{Key: k, Reason: MismatchMissingKey})
+				match.Status = MatchExcluded
+				continue
+			}
+
+			if !isKnown(tag.key) {
+				k = "???"
+			}
+			match.Mismatches = append(match.Mismatches, TagMismatch{Key: k, Reason: MismatchUnknownTag, DaemonValue: dv})
+			if match.Status == MatchAccepted {
+				match.Status = MatchUnknown
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// implicitTags are the tags with the scope and owner tags that coderd adds,
+// for a job or daemon of the owner.
+func implicitTags(tags map[string]string, ownerID string) map[string]string {
+	out := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		out[k] = v
+	}
+	switch out[TagScope] {
+	case ScopeUser:
+		out[TagOwner] = ownerID
+	default:
+		out[TagScope] = ScopeOrganization
+		out[TagOwner] = ""
+	}
+	return out
+}
+
+// hasUnknownKey reports if one of the unknown tags has the key, so its value
+// is not known.
+func hasUnknownKey(unknowns []Tag, key string) bool {
+	for _, tag := range unknowns {
+		if !isKnown(tag.key) || tag.SafeKeyString() == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_MatchProvisioners(t *testing.T) {
+	t.Parallel()
+
+	const owner = "8d6f0c5e-0d6c-4d6c-9b5e-3f1f2b8d9a11"
+	daemons := []coderism.ProvisionerDaemon{
+		{Name: "us", Tags: map[string]string{"zone": "us", "os": "linux", "gpu": "true"}},
+		{Name: "eu", Tags: map[string]string{"zone": "eu", "os": "linux"}},
+		{Name: "untagged", Tags: map[string]string{}},
+		{Name: "mine", Tags: map[string]string{"scope": "user", "owner": owner, "os": "linux"}},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		main   string
+		expect map[string]coderism.ProvisionerMatch
+	}{
+		{
+			name: "known tags",
+			main: `
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"zone" = "us"
+						"os"   = "linux"
+					}
+				}`,
+			expect: map[string]coderism.ProvisionerMatch{
+				"us": {Status: coderism.MatchAccepted, Mismatches: []coderism.TagMismatch{}},
+				"eu": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "zone", Reason: coderism.MismatchDifferentValue, Value: "us", DaemonValue: "eu"},
+				}},
+				"untagged": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "os", Reason: coderism.MismatchMissingKey, Value: "linux"},
+					{Key: "zone", Reason: coderism.MismatchMissingKey, Value: "us"},
+				}},
+				"mine": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "owner", Reason: coderism.MismatchDifferentValue, DaemonValue: owner},
+					{Key: "scope", Reason: coderism.MismatchDifferentValue, Value: "organization", DaemonValue: "user"},
+					{Key: "zone", Reason: coderism.MismatchMissingKey, Value: "us"},
+				}},
+			},
+		},
+		{
+			name: "user scope",
+			main: `
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"scope" = "user"
+						"os"    = "linux"
+					}
+				}`,
+			expect: map[string]coderism.ProvisionerMatch{
+				"us": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "owner", Reason: coderism.MismatchDifferentValue, Value: owner},
+					{Key: "scope", Reason: coderism.MismatchDifferentValue, Value: "user", DaemonValue: "organization"},
+				}},
+				"eu": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "owner", Reason: coderism.MismatchDifferentValue, Value: owner},
+					{Key: "scope", Reason: coderism.MismatchDifferentValue, Value: "user", DaemonValue: "organization"},
+				}},
+				"untagged": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "os", Reason: coderism.MismatchMissingKey, Value: "linux"},
+					{Key: "owner", Reason: coderism.MismatchDifferentValue, Value: owner},
+					{Key: "scope", Reason: coderism.MismatchDifferentValue, Value: "user", DaemonValue: "organization"},
+				}},
+				"mine": {Status: coderism.MatchAccepted, Mismatches: []coderism.TagMismatch{}},
+			},
+		},
+		{
+			name: "unknown tag",
+			main: `
+				resource "docker_image" "main" {
+					name = "ubuntu"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"os"   = "linux"
+						"zone" = docker_image.main.repo_digest
+					}
+				}`,
+			expect: map[string]coderism.ProvisionerMatch{
+				"us": {Status: coderism.MatchUnknown, Mismatches: []coderism.TagMismatch{
+					{Key: "zone", Reason: coderism.MismatchUnknownTag, DaemonValue: "us"},
+				}},
+				"eu": {Status: coderism.MatchUnknown, Mismatches: []coderism.TagMismatch{
+					{Key: "zone", Reason: coderism.MismatchUnknownTag, DaemonValue: "eu"},
+				}},
+				"untagged": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "os", Reason: coderism.MismatchMissingKey, Value: "linux"},
+					{Key: "zone", Reason: coderism.MismatchMissingKey},
+				}},
+				"mine": {Status: coderism.MatchExcluded, Mismatches: []coderism.TagMismatch{
+					{Key: "owner", Reason: coderism.MismatchDifferentValue, DaemonValue: owner},
+					{Key: "scope", Reason: coderism.MismatchDifferentValue, Value: "organization", DaemonValue: "user"},
+					{Key: "zone", Reason: coderism.MismatchMissingKey},
+				}},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			tags, _ := coderism.WorkspaceTags(modules)
+			matches, err := tags.MatchProvisioners(daemons, owner)
+			require.NoError(t, err)
+			require.Len(t, matches, len(daemons))
+
+			for _, m := range matches {
+				expect, ok := tc.expect[m.Daemon.Name]
+				require.True(t, ok, m.Daemon.Name)
+				require.Equal(t, expect.Status, m.Status, m.Daemon.Name)
+				require.Equal(t, expect.Mismatches, m.Mismatches, m.Daemon.Name)
+			}
+		})
+	}
+}
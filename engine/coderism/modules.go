@@ -0,0 +1,111 @@
+package coderism
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// moduleAddress is the address of the module that declares the block, like
+// 'module.code-server[0]'. It is empty for the root module.
+func moduleAddress(block *terraform.Block) string {
+	return strings.TrimSuffix(strings.TrimSuffix(block.FullName(), block.LocalName()), ".")
+}
+
+// moduleDisplayName is the module address, or "the root module".
+func moduleDisplayName(address string) string {
+	if address == "" {
+		return "the root module"
+	}
+	return fmt.Sprintf("%q", address)
+}
+
+// moduleManifestPath is where 'terraform init' records the modules it
+// downloaded, and where the parser looks for them.
+const moduleManifestPath = ".terraform/modules/modules.json"
+
+// moduleDiagnostics reports module calls that the parser could not load.
+// Remote modules are only loaded if 'terraform init' downloaded them, or if
+// they are in the module cache. Without them, the parameters and tags of the
+// module are missing from the preview.
+func moduleDiagnostics(modules terraform.Modules) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, module := range modules {
+		for _, call := range module.GetBlocks().OfType("module") {
+			source := call.GetAttribute("source").AsStringValueOrDefault("", call).Value()
+			if moduleLoaded(call, source) {
+				continue
+			}
+
+			r := call.HCLBlock().DefRange
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Module not loaded",
+				Detail: fmt.Sprintf("The module %q from %q is not available offline, so its parameters and tags are not part of the preview. "+
+					"Run 'terraform init' to download it, or add it to the module cache.", call.FullName(), source),
+				Subject: &r,
+			})
+		}
+	}
+	return diags
+}
+
+// moduleLoaded reports if the parser loaded the module of the call. Like the
+// parser, the module is in the directory the module manifest records for
+// the call, or in the local source directory, and a directory without
+// terraform files is not loaded. A loaded module does not have to declare
+// any blocks.
+func moduleLoaded(call *terraform.Block, source string) bool {
+	fsys := call.GetMetadata().Range().GetFS()
+	if fsys == nil {
+		return false
+	}
+
+	dir, ok := manifestModuleDir(fsys, call.ModuleKey())
+	if !ok {
+		if !strings.HasPrefix(source, ".") {
+			return false
+		}
+		dir = path.Join(path.Dir(call.HCLBlock().DefRange.Filename), source)
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".tf") || strings.HasSuffix(entry.Name(), ".tf.json")) {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestModuleDir is the directory of the module 'terraform init'
+// recorded for the module call key, like 'app.network'.
+func manifestModuleDir(fsys fs.FS, key string) (string, bool) {
+	data, err := fs.ReadFile(fsys, moduleManifestPath)
+	if err != nil {
+		return "", false
+	}
+	var manifest struct {
+		Modules []struct {
+			Key string `json:"Key"`
+			Dir string `json:"Dir"`
+		} `json:"Modules"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", false
+	}
+	for _, record := range manifest.Modules {
+		if record.Key == key {
+			return path.Clean(record.Dir), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,182 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_ModuleAddress(t *testing.T) {
+	t.Parallel()
+
+	const ideModule = `
+		data "coder_parameter" "ide" {
+			name    = "ide"
+			default = "vscode"
+		}
+
+		module "tools" {
+			source = "../tools"
+		}
+
+		data "coder_workspace_tags" "tags" {
+			tags = {
+				"ide" = data.coder_parameter.ide.value
+			}
+		}`
+
+	const toolsModule = `
+		data "coder_parameter" "tools" {
+			name    = "tools"
+			default = "git"
+		}`
+
+	for _, tc := range []struct {
+		name   string
+		main   string
+		params map[string]string
+		tags   map[string]string
+		errors []string
+	}{
+		{
+			name: "modules",
+			main: `
+				data "coder_parameter" "region" {
+					name    = "region"
+					default = "us"
+				}
+
+				module "ide" {
+					count  = 1
+					source = "./modules/ide"
+				}
+
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"region" = data.coder_parameter.region.value
+					}
+				}`,
+			params: map[string]string{
+				"region": "",
+				"ide":    "module.ide[0]",
+				"tools":  "module.ide[0].module.tools",
+			},
+			tags: map[string]string{
+				"region": "",
+				"ide":    "module.ide[0]",
+			},
+		},
+		{
+			name: "duplicate",
+			main: `
+				data "coder_parameter" "tools" {
+					name    = "tools"
+					default = "vim"
+				}
+
+				module "ide" {
+					source = "./modules/ide"
+				}`,
+			params: map[string]string{
+				"tools": "",
+				"ide":   "module.ide",
+			},
+			tags: map[string]string{
+				"ide": "module.ide",
+			},
+			errors: []string{"Duplicate parameter name"},
+		},
+		{
+			name: "duplicate in module",
+			main: `
+				data "coder_parameter" "region" {
+					name    = "region"
+					default = "us"
+				}
+
+				data "coder_parameter" "zone" {
+					name    = "region"
+					default = "eu"
+				}`,
+			params: map[string]string{
+				"region": "",
+			},
+			tags:   map[string]string{},
+			errors: []string{"Duplicate parameter name"},
+		},
+		{
+			// The instances of a block are not duplicates.
+			name: "expanded",
+			main: `
+				data "coder_parameter" "region" {
+					count   = 2
+					name    = "region"
+					default = "us"
+				}
+
+				module "ide" {
+					source = "./modules/ide"
+				}`,
+			params: map[string]string{
+				"region": "",
+				"ide":    "module.ide",
+				"tools":  "module.ide.module.tools",
+			},
+			tags: map[string]string{
+				"ide": "module.ide",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			for name, src := range map[string]string{
+				"main.tf":               tc.main,
+				"modules/ide/main.tf":   ideModule,
+				"modules/tools/main.tf": toolsModule,
+			} {
+				err := afero.WriteFile(memfs, name, []byte(src), 0644)
+				require.NoError(t, err)
+			}
+
+			input := coderism.Input{}
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), input)
+			var errors []string
+			for _, diag := range diags {
+				if diag.Severity == hcl.DiagError {
+					errors = append(errors, diag.Summary)
+				}
+			}
+			require.Equal(t, tc.errors, errors)
+
+			params := make(map[string]string)
+			for _, param := range output.Parameters {
+				// The root module is first, so a duplicate keeps the
+				// module of the first declaration.
+				if _, ok := params[param.Data.Name]; !ok {
+					params[param.Data.Name] = param.ModuleAddress
+				}
+			}
+			require.Equal(t, tc.params, params)
+
+			tags := make(map[string]string)
+			for _, block := range output.WorkspaceTags {
+				for _, tag := range block.Tags {
+					tags[tag.SafeKeyString()] = block.ModuleAddress
+				}
+			}
+			require.Equal(t, tc.tags, tags)
+		})
+	}
+}
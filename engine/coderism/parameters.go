@@ -1,18 +1,34 @@
 package coderism
 
 import (
+	"fmt"
+
 	"github.com/aquasecurity/trivy/pkg/iac/terraform"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
 
 	"github.com/coder/terraform-eval/engine/coderism/proto"
+	"github.com/coder/terraform-eval/engine/hclext"
 )
 
 type Parameter struct {
 	Data  *proto.RichParameter
 	Value ParameterValue
 	Block *terraform.Block
+	// InstanceKey is the 'count.index' or 'each.key' of a parameter block
+	// expanded by count or for_each. It is cty.NilVal otherwise.
+	InstanceKey cty.Value
+	// ModuleAddress is the module that declares the parameter, like
+	// 'module.code-server[0]'. It is empty for the root module.
+	ModuleAddress string
+}
+
+// InputName is the name of the parameter in the parameter values of the
+// input, which is the label of its block.
+func (p Parameter) InputName() string {
+	return parameterName(p.Block)
 }
 
 type ParameterValue struct {
@@ -23,15 +39,27 @@ type ParameterValue struct {
 	diags hcl.Diagnostics
 }
 
+// UnknownReasons returns why the value is not known. Known values have no
+// reasons.
+func (v ParameterValue) UnknownReasons() []UnknownMark {
+	return UnknownMarks(v.Value)
+}
+
 func (p Parameter) ValueAsString() (string, error) {
 	return CtyValueString(p.Value.Value)
 }
 
-func RichParameters(modules terraform.Modules) ([]Parameter, hcl.Diagnostics) {
+// RichParameters reads the coder_parameter blocks of every module. The files
+// are the source files the parser loaded, which have the blocks that the
+// evaluation dropped.
+func RichParameters(modules terraform.Modules, files map[string]*hcl.File) ([]Parameter, hcl.Diagnostics) {
 	rpDiags := make(hcl.Diagnostics, 0)
 
 	params := make([]Parameter, 0)
 	for _, module := range modules {
+		// Blocks with a count of 0 are already removed by the expansion.
+		rpDiags = rpDiags.Extend(expansionDiagnostics(module, files))
+
 		blocks := module.GetDatasByType("coder_parameter")
 		for _, block := range blocks {
 			p := newAttributeParser(block)
@@ -51,6 +79,9 @@ func RichParameters(modules terraform.Modules) ([]Parameter, hcl.Diagnostics) {
 			// Find the value of the parameter from the context.
 			paramValue, paramValueDiags := richParameterValue(block)
 
+			validation, validationDiags := paramValidation(block)
+			rpDiags = rpDiags.Extend(validationDiags)
+
 			param := Parameter{
 				Value: ParameterValue{
 					Value: paramValue,
@@ -59,48 +90,154 @@ func RichParameters(modules terraform.Modules) ([]Parameter, hcl.Diagnostics) {
 				Data: &proto.RichParameter{
 					Name:                p.attr("name").required().string(),
 					Description:         p.attr("description").string(),
-					Type:                "",
+					Type:                paramType(block),
 					Mutable:             p.attr("mutable").bool(),
-					DefaultValue:        "",
+					DefaultValue:        p.attr("default").primitiveString(),
 					Icon:                p.attr("icon").string(),
 					Options:             paramOptions,
-					ValidationRegex:     "",
-					ValidationError:     "",
-					ValidationMin:       nil,
-					ValidationMax:       nil,
-					ValidationMonotonic: "",
-					Required:            false,
-					DisplayName:         "",
-					Order:               0,
-					Ephemeral:           false,
+					ValidationRegex:     validation.ValidationRegex,
+					ValidationError:     validation.ValidationError,
+					ValidationMin:       validation.ValidationMin,
+					ValidationMax:       validation.ValidationMax,
+					ValidationMonotonic: validation.ValidationMonotonic,
+					Required:            paramRequired(block),
+					DisplayName:         p.attr("display_name").string(),
+					Order:               p.attr("order").int32(),
+					Ephemeral:           p.attr("ephemeral").bool(),
 				},
-				Block: block,
+				Block:         block,
+				InstanceKey:   instanceKey(block),
+				ModuleAddress: moduleAddress(block),
 			}
 			rpDiags = rpDiags.Extend(p.diags)
 			if p.diags.HasErrors() {
 				continue
 			}
 
+			rpDiags = rpDiags.Extend(validateParameter(param))
+			rpDiags = rpDiags.Extend(unknownParameterDiagnostics(param))
 			params = append(params, param)
 		}
 	}
-	return params, rpDiags
+	return params, rpDiags.Extend(duplicateParameters(params))
 }
 
+// duplicateParameters reports parameter names that are declared by more than
+// one block. Shared modules often declare parameters, and coder requires the
+// names to be unique, so the first declaration is shown with the duplicate.
+// The instances of a block expanded by count or for_each are one
+// declaration.
+func duplicateParameters(params []Parameter) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	seen := make(map[string]Parameter)
+	for _, param := range params {
+		prev, ok := seen[param.Data.Name]
+		if !ok {
+			seen[param.Data.Name] = param
+			continue
+		}
+		prevRange := prev.Block.HCLBlock().DefRange
+		r := param.Block.HCLBlock().DefRange
+		if prev.ModuleAddress == param.ModuleAddress && prevRange == r {
+			continue
+		}
+
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Duplicate parameter name",
+			Detail: fmt.Sprintf("The parameter %q in %s is already declared in %s at %s. Parameter names must be unique across modules.",
+				param.Data.Name, moduleDisplayName(param.ModuleAddress), moduleDisplayName(prev.ModuleAddress), prevRange.String()),
+			Subject: &r,
+		})
+	}
+	return diags
+}
+
+// richParameterValue reads the value of the parameter from the context. A
+// value that is not known carries an UnknownMark with the reason.
 func richParameterValue(block *terraform.Block) (cty.Value, hcl.Diagnostics) {
-	// Find the value of the parameter from the context.
-	paramPath := append([]string{"data"}, block.Labels()...)
-	valueRef := scopeTraversalExpr(append(paramPath, "value")...)
+	// Find the value of the parameter from the context. Expanded blocks
+	// are indexed by their instance key.
+	valueRef := scopeTraversalExpr("data", "coder_parameter", parameterName(block))
+	if key := instanceKey(block); key != cty.NilVal {
+		valueRef.Traversal = append(valueRef.Traversal, hcl.TraverseIndex{Key: key})
+	}
+	valueRef.Traversal = append(valueRef.Traversal, hcl.TraverseAttr{Name: "value"})
 	paramValue, diags := valueRef.Value(block.Context().Inner())
 	if diags != nil && diags.HasErrors() {
 		for _, diag := range diags {
 			b := block.HCLBlock().Body.MissingItemRange()
 			diag.Subject = &b
 		}
-		return cty.UnknownVal(cty.NilType), diags
+		return cty.DynamicVal.Mark(UnknownMark{
+			Reason:    UnknownParameterValue,
+			Reference: "data.coder_parameter." + parameterName(block),
+		}), diags
 	}
 
-	return paramValue, hcl.Diagnostics{}
+	return markUnknown(paramValue, cty.DynamicPseudoType, &valueRef), hcl.Diagnostics{}
+}
+
+// paramValidation reads the optional 'validation' block. Only the validation
+// fields of the returned RichParameter are set.
+func paramValidation(block *terraform.Block) (*proto.RichParameter, hcl.Diagnostics) {
+	validationBlocks := block.GetBlocks("validation")
+	if len(validationBlocks) == 0 {
+		return &proto.RichParameter{}, nil
+	}
+
+	var diags hcl.Diagnostics
+	if len(validationBlocks) > 1 {
+		r := validationBlocks[1].HCLBlock().DefRange
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Too many validation blocks",
+			Detail:   "At most one 'validation' block is allowed, only the first one is used.",
+			Subject:  &r,
+			Context:  &block.HCLBlock().DefRange,
+		})
+	}
+
+	p := newAttributeParser(validationBlocks[0])
+	rp := &proto.RichParameter{
+		ValidationRegex:     p.attr("regex").string(),
+		ValidationError:     p.attr("error").string(),
+		ValidationMin:       p.attr("min").optionalInt32(),
+		ValidationMax:       p.attr("max").optionalInt32(),
+		ValidationMonotonic: p.attr("monotonic").string(),
+	}
+	return rp, diags.Extend(p.diags)
+}
+
+// paramType returns the declared type of the parameter. The coder provider
+// defaults to "string" when no type is given. Both the legacy string literal
+// form (type = "number") and the keyword form (type = number) are accepted.
+func paramType(block *terraform.Block) string {
+	attr := block.GetAttribute("type")
+	if attr.IsNil() {
+		return "string"
+	}
+
+	expr := attr.HCLAttribute().Expr
+	if ty, _, err := hclext.DecodeVarType(expr); err == nil {
+		return typeexpr.TypeString(ty)
+	}
+
+	if attr.Type() == cty.String && attr.Value().IsKnown() {
+		return attr.Value().AsString()
+	}
+	return ""
+}
+
+// paramRequired matches the coder provider, which treats a parameter
+// without a 'default' as required.
+func paramRequired(block *terraform.Block) bool {
+	def := block.GetAttribute("default")
+	if def.IsNil() {
+		return true
+	}
+	val := def.Value()
+	return val.IsKnown() && val.IsNull()
 }
 
 func paramOption(block *terraform.Block) (*proto.RichParameterOption, hcl.Diagnostics) {
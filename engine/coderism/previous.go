@@ -0,0 +1,95 @@
+package coderism
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// validatePreviousValues compares the resolved parameter values with the
+// values of the previous workspace build. This previews what coderd does on
+// a workspace update:
+//   - Immutable parameters cannot change.
+//   - Monotonic parameters can only move in one direction.
+//   - Ephemeral parameters are reset to their default value, rather than
+//     reusing the previous value.
+func validatePreviousValues(params []Parameter, input Input) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, param := range params {
+		name := parameterName(param.Block)
+		prev, ok := input.PreviousRichParameterValue(name)
+		if !ok {
+			continue
+		}
+		_, given := input.RichParameterValue(name)
+
+		val := param.Value.Value
+		if val == cty.NilVal || !val.IsWhollyKnown() {
+			continue
+		}
+
+		prevVal, convDiags := parameterInputValue(param.Block, prev)
+		if convDiags.HasErrors() || !prevVal.IsWhollyKnown() {
+			// The previous value does not fit the current type. This is
+			// a change in itself, but nothing more can be compared.
+			continue
+		}
+
+		if prevVal.Equals(val).True() {
+			continue
+		}
+
+		r := param.Block.HCLBlock().DefRange
+		newStr, _ := param.ValueAsString()
+		switch {
+		case param.Data.Ephemeral:
+			if given {
+				// A new value is not a reset.
+				continue
+			}
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  fmt.Sprintf("Ephemeral parameter %q will be reset", param.Data.Name),
+				Detail:   fmt.Sprintf("The previous value %q is not kept for ephemeral parameters, the value will be %q.", prev.Value, newStr),
+				Subject:  &r,
+			})
+		case !param.Data.Mutable:
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Parameter %q is immutable", param.Data.Name),
+				Detail:   fmt.Sprintf("The value cannot be changed from %q to %q after the workspace is created.", prev.Value, newStr),
+				Subject:  &r,
+			})
+		default:
+			diags = diags.Extend(validateMonotonic(param, prevVal))
+		}
+	}
+	return diags
+}
+
+func validateMonotonic(param Parameter, prevVal cty.Value) hcl.Diagnostics {
+	val := param.Value.Value
+	if val.Type() != cty.Number || prevVal.Type() != cty.Number {
+		return nil
+	}
+
+	var violated bool
+	switch param.Data.ValidationMonotonic {
+	case "increasing":
+		violated = val.LessThan(prevVal).True()
+	case "decreasing":
+		violated = val.GreaterThan(prevVal).True()
+	default:
+		return nil
+	}
+	if !violated {
+		return nil
+	}
+
+	newStr, _ := CtyValueString(val)
+	prevStr, _ := CtyValueString(prevVal)
+	return hcl.Diagnostics{validationDiagnostic(param, validationBlock(param),
+		validationErrorMessage(param, newStr, fmt.Sprintf("Value must be %s, but changed from %s to %s.",
+			param.Data.ValidationMonotonic, prevStr, newStr)))}
+}
@@ -12,6 +12,15 @@ import (
 // It supports only primitive types - bool, number, and string.
 // As a special case, it also supports map[string]interface{} with key "value".
 func CtyValueString(val cty.Value) (string, error) {
+	if !val.IsWhollyKnown() {
+		return "", fmt.Errorf("value is not known")
+	}
+	// Marks, like an UnknownMark, do not change the string.
+	val, _ = val.UnmarkDeep()
+	if val.IsNull() {
+		return "", fmt.Errorf("value is null")
+	}
+
 	switch {
 	case val.Type().IsListType():
 		vals := val.AsValueSlice()
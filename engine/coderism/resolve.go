@@ -0,0 +1,269 @@
+package coderism
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/coder/terraform-eval/engine/hclext"
+)
+
+// resolveParameters sets the value of every coder_parameter block into the
+// evaluation context. A parameter's default can reference another
+// parameter, so the values are resolved until they stop changing. Each pass
+// resolves at least one more level of an acyclic dependency chain, so
+// len(params)+1 passes is always enough. Cycles never settle, and are
+// reported by ParameterCycles instead.
+func resolveParameters(ctx *tfcontext.Context, params terraform.Blocks, resolve func(block *terraform.Block) cty.Value) {
+	previous := make(map[*terraform.Block]cty.Value, len(params))
+	for i := 0; i <= len(params); i++ {
+		changed := false
+		values := make(map[*terraform.Block]cty.Value, len(params))
+		for _, block := range params {
+			value := resolve(block)
+			if old, ok := previous[block]; !ok || !old.RawEquals(value) {
+				changed = true
+			}
+			values[block] = value
+		}
+		setParameterValues(ctx, params, values)
+		previous = values
+
+		if !changed {
+			return
+		}
+	}
+}
+
+// ParameterCycles reports the dependency cycles between coder_parameter
+// blocks, once for each group of parameters that depend on each other.
+// Parameters in a cycle never resolve to a stable value.
+func ParameterCycles(modules terraform.Modules) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, module := range modules {
+		blocks := module.GetDatasByType("coder_parameter")
+
+		byName := make(map[string]*terraform.Block)
+		deps := make(map[string][]string)
+		for _, block := range blocks {
+			name := parameterName(block)
+			byName[name] = block
+			deps[name] = append(deps[name], parameterReferences(block)...)
+		}
+
+		for _, cycle := range findCycles(deps) {
+			path := make([]string, 0, len(cycle.Path))
+			onPath := make(map[string]bool)
+			for _, name := range cycle.Path {
+				path = append(path, "data.coder_parameter."+name)
+				onPath[name] = true
+			}
+			detail := fmt.Sprintf("The parameters depend on each other and cannot be resolved: %s", strings.Join(path, " -> "))
+
+			var others []string
+			for _, name := range cycle.Members {
+				if !onPath[name] {
+					others = append(others, "data.coder_parameter."+name)
+				}
+			}
+			if len(others) > 0 {
+				detail += fmt.Sprintf(". These parameters are part of the same cycle: %s", strings.Join(others, ", "))
+			}
+
+			r := byName[cycle.Path[0]].HCLBlock().DefRange
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Parameter dependency cycle",
+				Detail:   detail,
+				Subject:  &r,
+			})
+		}
+	}
+	return diags
+}
+
+// parameterReferences returns the names of the coder_parameter blocks that
+// the block references.
+func parameterReferences(block *terraform.Block) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ref := range blockReferences(block) {
+		parts := strings.Split(ref, ".")
+		if len(parts) < 3 || parts[0] != "data" || parts[1] != "coder_parameter" {
+			continue
+		}
+		if seen[parts[2]] {
+			continue
+		}
+		seen[parts[2]] = true
+		names = append(names, parts[2])
+	}
+	return names
+}
+
+// blockReferences returns the references of every attribute in the block,
+// including the attributes of nested blocks and meta arguments like count.
+func blockReferences(block *terraform.Block) []string {
+	body, ok := block.HCLBlock().Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+	return bodyReferences(body)
+}
+
+func bodyReferences(body *hclsyntax.Body) []string {
+	var refs []string
+	for _, attr := range body.Attributes {
+		refs = append(refs, hclext.ReferenceNames(attr.Expr)...)
+	}
+	for _, block := range body.Blocks {
+		if block.Type != "dynamic" || len(block.Labels) == 0 {
+			refs = append(refs, bodyReferences(block.Body)...)
+			continue
+		}
+
+		// References to the iterator of a dynamic block are local to
+		// the block, and not a dependency.
+		iterator := block.Labels[0]
+		if attr, ok := block.Body.Attributes["iterator"]; ok {
+			iterator = hcl.ExprAsKeyword(attr.Expr)
+		}
+		for _, ref := range bodyReferences(block.Body) {
+			if strings.HasPrefix(ref, iterator+".") {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// dependencyCycle is a group of nodes that depend on each other.
+type dependencyCycle struct {
+	// Path is a cycle through the group, which starts and ends with the
+	// same node.
+	Path []string
+	// Members are every node of the group, sorted. Overlapping cycles
+	// share nodes, so a group can have members that are not on the path.
+	Members []string
+}
+
+// findCycles returns a cycle for every strongly connected component of the
+// graph, which are the groups of nodes that depend on each other. Every
+// node on a cycle is a member of exactly one returned group, so overlapping
+// cycles are reported once, with all of their nodes.
+func findCycles(deps map[string][]string) []dependencyCycle {
+	// Sort to keep the output deterministic.
+	nodes := make([]string, 0, len(deps))
+	for node := range deps {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	// Tarjan's algorithm.
+	var (
+		index      = make(map[string]int)
+		lowlink    = make(map[string]int)
+		onStack    = make(map[string]bool)
+		stack      []string
+		components [][]string
+	)
+	var connect func(node string)
+	connect = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, dep := range deps[node] {
+			if _, ok := deps[dep]; !ok {
+				continue
+			}
+			if _, visited := index[dep]; !visited {
+				connect(dep)
+				lowlink[node] = min(lowlink[node], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[node] = min(lowlink[node], index[dep])
+			}
+		}
+
+		if lowlink[node] != index[node] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+	for _, node := range nodes {
+		if _, visited := index[node]; !visited {
+			connect(node)
+		}
+	}
+
+	var cycles []dependencyCycle
+	for _, component := range components {
+		sort.Strings(component)
+		if len(component) == 1 && !slices.Contains(deps[component[0]], component[0]) {
+			// A single node is only a cycle if it depends on itself.
+			continue
+		}
+		cycles = append(cycles, dependencyCycle{
+			Path:    cyclePath(deps, component),
+			Members: component,
+		})
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Members[0] < cycles[j].Members[0]
+	})
+	return cycles
+}
+
+// cyclePath is the shortest cycle from the first member of the component
+// back to itself.
+func cyclePath(deps map[string][]string, component []string) []string {
+	start := component[0]
+	members := make(map[string]bool, len(component))
+	for _, node := range component {
+		members[node] = true
+	}
+
+	// Breadth first search, so the path is the shortest one.
+	prev := make(map[string]string)
+	queue := []string{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, dep := range deps[node] {
+			if dep == start {
+				path := []string{start}
+				for n := node; n != start; n = prev[n] {
+					path = append(path, n)
+				}
+				slices.Reverse(path[1:])
+				return append(path, start)
+			}
+			if _, seen := prev[dep]; seen || !members[dep] {
+				continue
+			}
+			prev[dep] = node
+			queue = append(queue, dep)
+		}
+	}
+	// Unreachable, every member of a component reaches every other.
+	return append([]string{}, component...)
+}
@@ -0,0 +1,124 @@
+package coderism_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_ParameterResolution(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		main        string
+		expect      map[string]cty.Value
+		expectError string
+	}{
+		{
+			name: "chained defaults",
+			main: `
+				data "coder_parameter" "first" {
+					name    = "first"
+					default = "${data.coder_parameter.second.value}-first"
+				}
+				data "coder_parameter" "second" {
+					name    = "second"
+					default = "${data.coder_parameter.third.value}-second"
+				}
+				data "coder_parameter" "third" {
+					name    = "third"
+					default = "third"
+				}
+				data "coder_parameter" "fourth" {
+					name    = "fourth"
+					default = "${data.coder_parameter.first.value}-fourth"
+				}`,
+			expect: map[string]cty.Value{
+				"first":  cty.StringVal("third-second-first"),
+				"second": cty.StringVal("third-second"),
+				"third":  cty.StringVal("third"),
+				"fourth": cty.StringVal("third-second-first-fourth"),
+			},
+		},
+		{
+			name: "cycle",
+			main: `
+				data "coder_parameter" "a" {
+					name    = "a"
+					default = data.coder_parameter.b.value
+				}
+				data "coder_parameter" "b" {
+					name    = "b"
+					default = data.coder_parameter.c.value
+				}
+				data "coder_parameter" "c" {
+					name    = "c"
+					default = "c"
+					option {
+						name  = "A"
+						value = data.coder_parameter.a.value
+					}
+				}`,
+			expectError: "data.coder_parameter.a -> data.coder_parameter.b -> data.coder_parameter.c -> data.coder_parameter.a",
+		},
+		{
+			// a -> c -> a and a -> b -> c -> a share their nodes, and are
+			// reported together.
+			name: "overlapping cycles",
+			main: `
+				data "coder_parameter" "a" {
+					name    = "a"
+					default = "${data.coder_parameter.b.value}-${data.coder_parameter.c.value}"
+				}
+				data "coder_parameter" "b" {
+					name    = "b"
+					default = data.coder_parameter.c.value
+				}
+				data "coder_parameter" "c" {
+					name    = "c"
+					default = data.coder_parameter.a.value
+				}`,
+			expectError: "data.coder_parameter.a -> data.coder_parameter.c -> data.coder_parameter.a. These parameters are part of the same cycle: data.coder_parameter.b",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), coderism.Input{})
+			if tc.expectError != "" {
+				require.True(t, diags.HasErrors())
+				// Unknown values are reported too, so look at every
+				// diagnostic.
+				details := make([]string, 0, len(diags))
+				for _, d := range diags {
+					details = append(details, d.Detail)
+				}
+				require.Contains(t, strings.Join(details, "\n"), tc.expectError)
+				return
+			}
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			got := make(map[string]cty.Value)
+			for _, param := range output.Parameters {
+				got[param.Data.Name] = param.Value.Value
+			}
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
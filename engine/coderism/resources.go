@@ -0,0 +1,177 @@
+package coderism
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Resource is a resource block of the template, and the instances the
+// template creates from it.
+type Resource struct {
+	// Address is the address of the block, like 'module.app[0].docker_container.workspace'.
+	// It has no instance key.
+	Address string
+	Type    string
+	Name    string
+	// Provider is the provider configuration of the resource, like 'aws' or
+	// 'aws.west' with an alias.
+	Provider string
+	// Expansion is "count", "for_each", or "" for a single instance.
+	Expansion string
+	// CountKnown is false if the count or for_each is not known during the
+	// preview. Instances is empty then.
+	CountKnown bool
+	// Instances are the keys of the instances, as written in a reference,
+	// like '0' or '"dev"'. A block that is not expanded has no keys.
+	Instances []string
+	Range     hcl.Range
+}
+
+// InstanceCount is the number of instances the block creates, or -1 if not
+// known.
+func (r Resource) InstanceCount() int {
+	switch {
+	case !r.CountKnown:
+		return -1
+	case r.Expansion == "":
+		return 1
+	}
+	return len(r.Instances)
+}
+
+// Resources lists the resource blocks of every module, and their instances.
+// Blocks with a count of 0, or a for_each that is not known, are not in the
+// evaluated modules, so they are found in the files the parser loaded.
+func Resources(modules terraform.Modules, files map[string]*hcl.File) []Resource {
+	var resources []Resource
+	for _, module := range modules {
+		resources = append(resources, moduleResources(module, files)...)
+	}
+	return resources
+}
+
+func moduleResources(module *terraform.Module, files map[string]*hcl.File) []Resource {
+	blocks := module.GetBlocks()
+	if len(blocks) == 0 {
+		return nil
+	}
+	// Every block of the module has the same module address, like
+	// 'module.app[0].'.
+	prefix := strings.TrimSuffix(blocks[0].FullName(), blocks[0].LocalName())
+
+	var resources []Resource
+	byAddress := make(map[string]int)
+	for _, block := range blocks.OfType("resource") {
+		// The labels of an instance have the key, like 'name[0]', so the
+		// name comes from the reference.
+		name := block.Reference().NameLabel()
+		address := prefix + block.TypeLabel() + "." + name
+
+		idx, ok := byAddress[address]
+		if !ok {
+			idx = len(resources)
+			byAddress[address] = idx
+			resources = append(resources, Resource{
+				Address:    address,
+				Type:       block.TypeLabel(),
+				Name:       name,
+				Provider:   resourceProvider(block.TypeLabel(), block.HCLBlock().Body),
+				Expansion:  expansionKind(block.HCLBlock().Body),
+				CountKnown: true,
+				Range:      block.HCLBlock().DefRange,
+			})
+		}
+
+		if !block.IsExpanded() {
+			continue
+		}
+		if countAttr := block.GetAttribute("count"); !countAttr.IsNil() {
+			// An unknown count is expanded to a single instance, which is
+			// not a real instance.
+			count, diags := countAttr.HCLAttribute().Expr.Value(block.Context().Inner())
+			if diags.HasErrors() || !isKnown(count) || !count.IsWhollyKnown() {
+				resources[idx].CountKnown = false
+				resources[idx].Instances = nil
+				continue
+			}
+		}
+		if resources[idx].CountKnown {
+			resources[idx].Instances = append(resources[idx].Instances, instanceKeyString(instanceKey(block)))
+		}
+	}
+
+	// Find the blocks that have no instances.
+	evalCtx := blocks[0].Context().Root().Inner()
+	for _, block := range declaredBlocks(files, blocks) {
+		if block.Type != "resource" {
+			continue
+		}
+		address := prefix + block.Labels[0] + "." + block.Labels[1]
+		if _, ok := byAddress[address]; ok {
+			continue
+		}
+
+		resource := Resource{
+			Address:    address,
+			Type:       block.Labels[0],
+			Name:       block.Labels[1],
+			Provider:   resourceProvider(block.Labels[0], block.Body),
+			Expansion:  expansionKind(block.Body),
+			CountKnown: true,
+			Range:      block.DefRange,
+		}
+		if attr, ok := metaArguments(block.Body)[resource.Expansion]; ok {
+			val, diags := attr.Expr.Value(evalCtx)
+			resource.CountKnown = !diags.HasErrors() && val.IsWhollyKnown()
+		}
+		byAddress[address] = len(resources)
+		resources = append(resources, resource)
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].Address < resources[j].Address
+	})
+	return resources
+}
+
+// expansionKind is the meta-argument that expands the block, if any.
+func expansionKind(body hcl.Body) string {
+	attrs := metaArguments(body)
+	for _, kind := range []string{"count", "for_each"} {
+		if _, ok := attrs[kind]; ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+// resourceProvider is the 'provider' meta-argument, or the provider implied
+// by the resource type, like 'docker' for 'docker_container'.
+func resourceProvider(resourceType string, body hcl.Body) string {
+	if attr, ok := metaArguments(body)["provider"]; ok {
+		if traversal, diags := hcl.AbsTraversalForExpr(attr.Expr); !diags.HasErrors() {
+			return traversalString(traversal)
+		}
+	}
+	provider, _, _ := strings.Cut(resourceType, "_")
+	return provider
+}
+
+// instanceKeyString formats an instance key like it is written in a
+// reference, '0' or '"dev"'.
+func instanceKeyString(key cty.Value) string {
+	switch {
+	case key == cty.NilVal || !key.IsKnown() || key.IsNull():
+		return ""
+	case key.Type() == cty.String:
+		return fmt.Sprintf("%q", key.AsString())
+	case key.Type() == cty.Number:
+		return key.AsBigFloat().String()
+	}
+	return ""
+}
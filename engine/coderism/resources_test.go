@@ -0,0 +1,118 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+func Test_Resources(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		data "coder_parameter" "replicas" {
+			name    = "replicas"
+			type    = "number"
+			default = 3
+		}
+
+		variable "envs" {
+			type    = list(string)
+			default = ["dev", "prod"]
+		}
+
+		resource "docker_container" "workspace" {
+			count = data.coder_parameter.replicas.value
+			image = "ubuntu"
+		}
+
+		resource "docker_volume" "env" {
+			for_each = toset(var.envs)
+			name     = each.key
+		}
+
+		resource "aws_instance" "gpu" {
+			provider = aws.west
+			count    = 0
+		}
+
+		resource "aws_instance" "spot" {
+			count = aws_instance.gpu.missing
+		}
+
+		resource "coder_agent" "main" {
+			os   = "linux"
+			arch = "amd64"
+		}`
+
+	for _, tc := range []struct {
+		name   string
+		input  coderism.Input
+		expect map[string]coderism.Resource
+	}{
+		{
+			name: "defaults",
+			expect: map[string]coderism.Resource{
+				"docker_container.workspace": {Provider: "docker", Expansion: "count", CountKnown: true, Instances: []string{"0", "1", "2"}},
+				"docker_volume.env":          {Provider: "docker", Expansion: "for_each", CountKnown: true, Instances: []string{`"dev"`, `"prod"`}},
+				"aws_instance.gpu":           {Provider: "aws.west", Expansion: "count", CountKnown: true},
+				"aws_instance.spot":          {Provider: "aws", Expansion: "count"},
+				"coder_agent.main":           {Provider: "coder", CountKnown: true},
+				"docker_network.unused":      {Provider: "docker", Expansion: "count", CountKnown: true},
+			},
+		},
+		{
+			name: "input",
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "replicas", Value: "1"},
+				},
+			},
+			expect: map[string]coderism.Resource{
+				"docker_container.workspace": {Provider: "docker", Expansion: "count", CountKnown: true, Instances: []string{"0"}},
+				"docker_volume.env":          {Provider: "docker", Expansion: "for_each", CountKnown: true, Instances: []string{`"dev"`, `"prod"`}},
+				"aws_instance.gpu":           {Provider: "aws.west", Expansion: "count", CountKnown: true},
+				"aws_instance.spot":          {Provider: "aws", Expansion: "count"},
+				"coder_agent.main":           {Provider: "coder", CountKnown: true},
+				"docker_network.unused":      {Provider: "docker", Expansion: "count", CountKnown: true},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+			// A block without instances in a JSON file.
+			err = afero.WriteFile(memfs, "network.tf.json", []byte(`{
+				"resource": {"docker_network": {"unused": {"count": 0, "name": "unused"}}}
+			}`), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, _ := coderism.Extract(modules, psr.Files(), tc.input)
+			got := make(map[string]coderism.Resource)
+			for _, r := range output.Resources {
+				require.Equal(t, r.Type+"."+r.Name, r.Address)
+				// Only compare the fields under test.
+				got[r.Address] = coderism.Resource{
+					Provider:   r.Provider,
+					Expansion:  r.Expansion,
+					CountKnown: r.CountKnown,
+					Instances:  r.Instances,
+				}
+			}
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package coderism
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// stateValues are the attribute values of the resources and data sources
+// in a state, by address, like 'module.app.docker_image.ubuntu[0]'.
+type stateValues map[string]map[string]cty.Value
+
+func newStateValues(state *tfjson.State) stateValues {
+	values := make(stateValues)
+	if state == nil || state.Values == nil {
+		return values
+	}
+
+	var walk func(module *tfjson.StateModule)
+	walk = func(module *tfjson.StateModule) {
+		if module == nil {
+			return
+		}
+		for _, resource := range module.Resources {
+			values[resource.Address] = stateAttributeValues(resource.AttributeValues)
+		}
+		for _, child := range module.ChildModules {
+			walk(child)
+		}
+	}
+	walk(state.Values.RootModule)
+	return values
+}
+
+// stateAttributeValues converts the JSON attribute values of a resource.
+// A value that cannot be converted is left out, so it is unknown like the
+// values that are not in the state.
+func stateAttributeValues(attrs map[string]interface{}) map[string]cty.Value {
+	values := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		if attr == nil {
+			// Unknown values are null in the state, so they are left out.
+			continue
+		}
+
+		val, err := stateAttributeValue(attr)
+		if err != nil {
+			continue
+		}
+		values[name] = val
+	}
+	return values
+}
+
+// stateAttributeValue converts a JSON attribute value. The type is implied by
+// the value, as the provider schemas are not known.
+func stateAttributeValue(attr interface{}) (cty.Value, error) {
+	data, err := json.Marshal(attr)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("marshal: %w", err)
+	}
+	ty, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("type: %w", err)
+	}
+	val, err := ctyjson.Unmarshal(data, ty)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("unmarshal: %w", err)
+	}
+	return val, nil
+}
+
+// lookup returns the state values of the block, without the attributes
+// the block sets itself. The configuration is newer than the state, so it
+// wins.
+func (s stateValues) lookup(block *terraform.Block) map[string]cty.Value {
+	attrs, ok := s[block.FullName()]
+	if !ok {
+		return nil
+	}
+
+	values := make(map[string]cty.Value, len(attrs))
+	for name, val := range attrs {
+		if !block.GetAttribute(name).IsNil() {
+			continue
+		}
+		values[name] = val
+	}
+	return values
+}
+
+// StateEvalHook sets the attributes of resources and data sources from the
+// state of an existing workspace. Without a state, the attributes computed
+// by the provider are not known during the preview.
+func StateEvalHook(input Input) func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+	values := newStateValues(input.State)
+
+	return func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+		if len(values) == 0 {
+			return
+		}
+
+		type expansion struct {
+			parts  []string
+			blocks terraform.Blocks
+		}
+		expanded := make(map[string]*expansion)
+		for _, block := range blocks {
+			var parts []string
+			switch block.Type() {
+			case "resource":
+				parts = []string{block.TypeLabel(), block.Reference().NameLabel()}
+			case "data":
+				if block.TypeLabel() == "coder_parameter" {
+					continue
+				}
+				parts = []string{"data", block.TypeLabel(), block.Reference().NameLabel()}
+			default:
+				continue
+			}
+
+			if block.IsExpanded() {
+				k := strings.Join(parts, ".")
+				if expanded[k] == nil {
+					expanded[k] = &expansion{parts: parts}
+				}
+				expanded[k].blocks = append(expanded[k].blocks, block)
+				continue
+			}
+
+			for attr, val := range values.lookup(block) {
+				ctx.Set(val, append(parts, attr)...)
+			}
+		}
+
+		for _, e := range expanded {
+			found := false
+			for _, block := range e.blocks {
+				if _, ok := values[block.FullName()]; ok {
+					found = true
+				}
+			}
+			if found {
+				ctx.Set(expandedBlocksValue(e.blocks, values.lookup), e.parts...)
+			}
+		}
+	}
+}
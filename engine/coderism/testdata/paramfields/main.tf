@@ -0,0 +1,37 @@
+terraform {
+  required_providers {
+    coder = {
+      source = "coder/coder"
+    }
+  }
+}
+
+data "coder_parameter" "cpu" {
+  name         = "cpu"
+  display_name = "CPU Cores"
+  description  = "Number of cores"
+  type         = "number"
+  icon         = "/icon/cpu.svg"
+  mutable      = true
+  default      = 4
+  order        = 2
+
+  validation {
+    min       = 2
+    max       = 8
+    monotonic = "increasing"
+    error     = "Between 2 and 8 cores"
+  }
+}
+
+data "coder_parameter" "name" {
+  name      = "name"
+  type      = string
+  ephemeral = true
+  order     = 1
+
+  validation {
+    regex = "^[a-z]+$"
+    error = "Lowercase letters only"
+  }
+}
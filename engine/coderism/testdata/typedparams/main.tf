@@ -0,0 +1,33 @@
+terraform {
+  required_providers {
+    coder = {
+      source = "coder/coder"
+    }
+  }
+}
+
+data "coder_parameter" "gpu" {
+  name    = "gpu"
+  type    = "bool"
+  default = false
+}
+
+data "coder_parameter" "cores" {
+  name    = "cores"
+  type    = "number"
+  default = 2
+}
+
+data "coder_parameter" "regions" {
+  name    = "regions"
+  type    = "list(string)"
+  default = jsonencode(["us"])
+}
+
+data "coder_workspace_tags" "tags" {
+  tags = {
+    "gpu"    = data.coder_parameter.gpu.value == true ? "yes" : "no"
+    "large"  = data.coder_parameter.cores.value > 4 ? "yes" : "no"
+    "region" = data.coder_parameter.regions.value[0]
+  }
+}
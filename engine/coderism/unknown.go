@@ -0,0 +1,392 @@
+package coderism
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownReason is why a value is not known during the preview.
+type UnknownReason string
+
+const (
+	// UnknownResourceAttribute is an attribute of a resource that is only
+	// known after apply.
+	UnknownResourceAttribute UnknownReason = "resource_attribute"
+	// UnknownDataSource is an attribute of a data source that has no stub,
+	// so it is only known when the provider reads it.
+	UnknownDataSource UnknownReason = "data_source"
+	// UnknownParameterValue is a parameter without a value, or with a value
+	// that cannot be used.
+	UnknownParameterValue UnknownReason = "parameter_value"
+	// UnknownImpureFunction is the result of a function like 'uuid', which
+	// returns a different value every time.
+	UnknownImpureFunction UnknownReason = "impure_function"
+	// UnknownOther is anything else, like a module output.
+	UnknownOther UnknownReason = "other"
+)
+
+// UnknownMark is a cty mark on unknown values. Operations on a marked value
+// keep the mark, so anything computed from an unknown value still says where
+// it comes from.
+type UnknownMark struct {
+	Reason UnknownReason
+	// Reference is what is not known, like 'docker_image.main.repo_digest'.
+	Reference string
+}
+
+func (m UnknownMark) String() string {
+	switch m.Reason {
+	case UnknownResourceAttribute:
+		return fmt.Sprintf("%s is only known after apply", m.Reference)
+	case UnknownDataSource:
+		return fmt.Sprintf("%s is read by the provider, and has no stub", m.Reference)
+	case UnknownParameterValue:
+		return fmt.Sprintf("%s has no value", m.Reference)
+	case UnknownImpureFunction:
+		return fmt.Sprintf("%s returns a different value every time", m.Reference)
+	}
+	if m.Reference == "" {
+		return "the value is not known during the preview"
+	}
+	return fmt.Sprintf("%s is not known during the preview", m.Reference)
+}
+
+// UnknownMarks returns the unknown marks of the value and of every value
+// nested in it, sorted by reference.
+func UnknownMarks(val cty.Value) []UnknownMark {
+	_, marks := val.UnmarkDeep()
+	var unknowns []UnknownMark
+	for mark := range marks {
+		if um, ok := mark.(UnknownMark); ok {
+			unknowns = append(unknowns, um)
+		}
+	}
+	sort.Slice(unknowns, func(i, j int) bool {
+		if unknowns[i].Reference != unknowns[j].Reference {
+			return unknowns[i].Reference < unknowns[j].Reference
+		}
+		return unknowns[i].Reason < unknowns[j].Reason
+	})
+	return unknowns
+}
+
+// markUnknown makes sure a value that is not known has an unknown mark.
+// UnknownsEvalHook marks the sources of unknown values in the evaluation
+// context, so a value computed from them has their marks already. A value
+// without marks is not known because of the expression itself, like a call
+// to an impure function. Known values are returned without marks.
+func markUnknown(val cty.Value, ty cty.Type, expr hcl.Expression) cty.Value {
+	if isKnown(val) {
+		val, _ = val.UnmarkDeep()
+		return val
+	}
+	if len(UnknownMarks(val)) > 0 {
+		return val
+	}
+
+	if val != cty.NilVal {
+		ty = val.Type()
+	}
+	mark := UnknownMark{Reason: UnknownOther}
+	if name, ok := impureCall(expr); ok {
+		mark = UnknownMark{Reason: UnknownImpureFunction, Reference: name + "()"}
+	}
+	return cty.UnknownVal(ty).Mark(mark)
+}
+
+// UnknownsEvalHook sets the attributes of resources, data sources and
+// modules that the template references, but that are not known during the
+// preview, to unknown values marked with the reason. Anything computed from
+// them keeps the mark. It runs after the hooks that set known values, like
+// StateEvalHook, so it only sets what they did not.
+func UnknownsEvalHook() func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+	return func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+		refs := make(map[string]map[string]bool)
+		for _, block := range blocks {
+			for _, traversal := range blockTraversals(block) {
+				addReferencedAttribute(refs, traversal)
+			}
+		}
+		setUnknownAttributes(ctx, blocks, refs)
+	}
+}
+
+// blockTraversals are the references of the attributes of the block and of
+// its nested blocks.
+func blockTraversals(block *terraform.Block) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	for _, attr := range block.GetAttributes() {
+		traversals = append(traversals, attr.HCLAttribute().Expr.Variables()...)
+	}
+	for _, child := range block.AllBlocks() {
+		traversals = append(traversals, blockTraversals(child)...)
+	}
+	return traversals
+}
+
+// addReferencedAttribute adds the attribute of a resource, data source or
+// module that the traversal references, by the address of the block, like
+// 'docker_image.main'.
+func addReferencedAttribute(refs map[string]map[string]bool, traversal hcl.Traversal) {
+	attrs := traversalAttrs(traversal)
+	if len(attrs) == 0 {
+		return
+	}
+
+	n := 2
+	switch attrs[0] {
+	case "data":
+		n = 3
+	case "var", "local", "count", "each", "path", "self", "terraform":
+		return
+	}
+	if len(attrs) <= n {
+		return
+	}
+
+	address := strings.Join(attrs[:n], ".")
+	if refs[address] == nil {
+		refs[address] = make(map[string]bool)
+	}
+	refs[address][attrs[n]] = true
+}
+
+// setUnknownAttributes sets the referenced attributes that are not known in
+// the context. The attributes the template does not set are the sources of
+// unknown values, so they are set first, and then the attributes computed
+// from them.
+func setUnknownAttributes(ctx *tfcontext.Context, blocks terraform.Blocks, refs map[string]map[string]bool) {
+	for _, configured := range []bool{false, true} {
+		setBlocksUnknownAttributes(ctx, blocks, refs, configured)
+	}
+}
+
+// setBlocksUnknownAttributes sets the referenced attributes that are, or are
+// not, configured in the template. Blocks expanded by count or for_each are
+// set as a tuple or object of their instances, like DataFixturesEvalHook
+// does.
+func setBlocksUnknownAttributes(ctx *tfcontext.Context, blocks terraform.Blocks, refs map[string]map[string]bool, configured bool) {
+	expanded := make(map[string]terraform.Blocks)
+	for _, block := range blocks {
+		if _, ok := unknownAttributeReason(block); !ok {
+			continue
+		}
+		address := blockAddress(block)
+		if len(refs[address]) == 0 {
+			continue
+		}
+		if block.IsExpanded() {
+			expanded[address] = append(expanded[address], block)
+			continue
+		}
+
+		parts := strings.Split(address, ".")
+		for attr, val := range unknownAttributes(block, refs[address], ctx.Get(parts...), configured) {
+			ctx.Set(val, append(parts, attr)...)
+		}
+	}
+
+	for address, instances := range expanded {
+		parts := strings.Split(address, ".")
+		current := ctx.Get(parts...)
+		ctx.Set(expandedBlocksValue(instances, func(block *terraform.Block) map[string]cty.Value {
+			// Keep the values that are set already, like the values of
+			// the state.
+			instance := instanceValue(current, instanceKey(block))
+			values := make(map[string]cty.Value)
+			if instance != cty.NilVal && instance.IsKnown() && !instance.IsNull() && instance.Type().IsObjectType() {
+				values = instance.AsValueMap()
+			}
+			for attr, val := range unknownAttributes(block, refs[address], instance, configured) {
+				values[attr] = val
+			}
+			return values
+		}), parts...)
+	}
+}
+
+// unknownAttributes are the marked unknown values of the referenced
+// attributes that the current value of the block does not have a known
+// value for. Only the attributes that are, or are not, configured in the
+// template are returned.
+func unknownAttributes(block *terraform.Block, names map[string]bool, current cty.Value, configured bool) map[string]cty.Value {
+	reason, _ := unknownAttributeReason(block)
+	values := make(map[string]cty.Value)
+	for name := range names {
+		attr := block.GetAttribute(name)
+		if isKnown(objectAttribute(current, name)) || configured != (block.Type() != "module" && !attr.IsNil()) {
+			continue
+		}
+
+		mark := UnknownMark{Reason: reason, Reference: block.LocalName() + "." + name}
+		if configured {
+			// The attribute is not known because of its expression.
+			val, _ := attr.HCLAttribute().Expr.Value(block.Context().Inner())
+			if len(UnknownMarks(val)) > 0 {
+				values[name] = val
+				continue
+			}
+			mark.Reason = UnknownOther
+			if fn, ok := impureCall(attr.HCLAttribute().Expr); ok {
+				mark = UnknownMark{Reason: UnknownImpureFunction, Reference: fn + "()"}
+			}
+		}
+		values[name] = cty.DynamicVal.Mark(mark)
+	}
+	return values
+}
+
+// unknownAttributeReason is why the attributes of the block that are not
+// set are not known. Parameters are marked when their value is set.
+func unknownAttributeReason(block *terraform.Block) (UnknownReason, bool) {
+	switch block.Type() {
+	case "resource":
+		return UnknownResourceAttribute, true
+	case "data":
+		return UnknownDataSource, block.TypeLabel() != "coder_parameter"
+	case "module":
+		return UnknownOther, true
+	}
+	return "", false
+}
+
+// blockAddress is the address of the block in references, without the
+// instance key, like 'data.docker_image.main'.
+func blockAddress(block *terraform.Block) string {
+	// The labels of an instance have the key, like 'name[0]', so the name
+	// comes from the reference.
+	name := block.Reference().NameLabel()
+	switch block.Type() {
+	case "data":
+		return "data." + block.TypeLabel() + "." + name
+	case "module":
+		return "module." + name
+	}
+	return block.TypeLabel() + "." + name
+}
+
+// objectAttribute is the attribute of an object value, or cty.NilVal.
+func objectAttribute(val cty.Value, name string) cty.Value {
+	if val == cty.NilVal || !val.IsKnown() || val.IsNull() || !val.Type().IsObjectType() || !val.Type().HasAttribute(name) {
+		return cty.NilVal
+	}
+	return val.GetAttr(name)
+}
+
+// instanceValue is the value of the instance with the key in the value of
+// every instance of an expanded block, or cty.NilVal.
+func instanceValue(all cty.Value, key cty.Value) cty.Value {
+	if all == cty.NilVal || !all.IsKnown() || all.IsNull() || key == cty.NilVal || !key.IsKnown() {
+		return cty.NilVal
+	}
+	switch {
+	case key.Type() == cty.String:
+		return objectAttribute(all, key.AsString())
+	case key.Type() == cty.Number && all.Type().IsTupleType():
+		i, _ := key.AsBigFloat().Int64()
+		if i >= 0 && int(i) < all.LengthInt() {
+			return all.Index(key)
+		}
+	}
+	return cty.NilVal
+}
+
+// impureFunctions return a different value on every call, so their result
+// is not known until apply.
+var impureFunctions = map[string]bool{
+	"bcrypt":    true,
+	"timestamp": true,
+	"uuid":      true,
+}
+
+// impureCall returns the name of the first impure function called in the
+// expression.
+func impureCall(expr hcl.Expression) (string, bool) {
+	syntaxExpr, ok := expr.(hclsyntax.Expression)
+	if !ok {
+		return "", false
+	}
+
+	var name string
+	_ = hclsyntax.VisitAll(syntaxExpr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := node.(*hclsyntax.FunctionCallExpr); ok && name == "" && impureFunctions[call.Name] {
+			name = call.Name
+		}
+		return nil
+	})
+	return name, name != ""
+}
+
+// markParameterValue marks the value of a coder_parameter that is not
+// known. A default that calls an impure function is not known either, even
+// if the evaluation returns a value for it.
+func markParameterValue(block *terraform.Block, value cty.Value, hasInput bool) cty.Value {
+	ty, _, _ := coderParameterType(block)
+	if ty == cty.NilType {
+		ty = cty.DynamicPseudoType
+	}
+
+	def := block.GetAttribute("default")
+	if !hasInput && !def.IsNil() {
+		if name, ok := impureCall(def.HCLAttribute().Expr); ok {
+			return cty.UnknownVal(ty).Mark(UnknownMark{Reason: UnknownImpureFunction, Reference: name + "()"})
+		}
+	}
+
+	if isKnown(value) {
+		return value
+	}
+	if hasInput || def.IsNil() {
+		// The input value cannot be converted, or there is no value at all.
+		return cty.UnknownVal(ty).Mark(UnknownMark{
+			Reason:    UnknownParameterValue,
+			Reference: "data.coder_parameter." + parameterName(block),
+		})
+	}
+	// The default is not known. Its value has the marks of what it
+	// references, which NullableValue drops.
+	val, _ := def.HCLAttribute().Expr.Value(block.Context().Inner())
+	if len(UnknownMarks(val)) > 0 {
+		_, all := val.UnmarkDeep()
+		return cty.UnknownVal(ty).WithMarks(all)
+	}
+	return markUnknown(value, ty, def.HCLAttribute().Expr)
+}
+
+// unknownDetail adds the unknown reasons of the value to a diagnostic
+// detail.
+func unknownDetail(detail string, val cty.Value) string {
+	marks := UnknownMarks(val)
+	if len(marks) == 0 {
+		return detail
+	}
+
+	reasons := make([]string, 0, len(marks))
+	for _, mark := range marks {
+		reasons = append(reasons, mark.String())
+	}
+	return fmt.Sprintf("%s: %s.", detail, strings.Join(reasons, ", "))
+}
+
+// unknownParameterDiagnostics warns about a parameter value that is not
+// known, with the reason why.
+func unknownParameterDiagnostics(param Parameter) hcl.Diagnostics {
+	if isKnown(param.Value.Value) {
+		return nil
+	}
+
+	r := param.Block.HCLBlock().DefRange
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagWarning,
+		Summary:  "Parameter value is not known",
+		Detail:   unknownDetail(fmt.Sprintf("The value of parameter %q is not known during the preview", param.Data.Name), param.Value.Value),
+		Subject:  &r,
+	}}
+}
@@ -0,0 +1,131 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+func Test_UnknownReasons(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name         string
+		main         string
+		expectTag    []coderism.UnknownMark
+		expectParams map[string][]coderism.UnknownMark
+	}{
+		{
+			name: "resource attribute",
+			main: `
+				resource "docker_image" "main" {
+					name = "ubuntu"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"tag" = docker_image.main.repo_digest
+					}
+				}`,
+			expectTag: []coderism.UnknownMark{
+				{Reason: coderism.UnknownResourceAttribute, Reference: "docker_image.main.repo_digest"},
+			},
+		},
+		{
+			name: "attribute of an expanded resource",
+			main: `
+				resource "docker_image" "main" {
+					count = 2
+					name  = "ubuntu"
+				}
+				resource "docker_container" "main" {
+					image = docker_image.main[1].repo_digest
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"tag" = docker_container.main.image
+					}
+				}`,
+			expectTag: []coderism.UnknownMark{
+				{Reason: coderism.UnknownResourceAttribute, Reference: "docker_image.main[1].repo_digest"},
+			},
+		},
+		{
+			name: "data source",
+			main: `
+				data "docker_registry_image" "main" {
+					name = "ubuntu"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"tag" = data.docker_registry_image.main.sha256_digest
+					}
+				}`,
+			expectTag: []coderism.UnknownMark{
+				{Reason: coderism.UnknownDataSource, Reference: "data.docker_registry_image.main.sha256_digest"},
+			},
+		},
+		{
+			name: "missing parameter value",
+			main: `
+				data "coder_parameter" "region" {
+					name = "region"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"tag" = "zone-${data.coder_parameter.region.value}"
+					}
+				}`,
+			expectTag: []coderism.UnknownMark{
+				{Reason: coderism.UnknownParameterValue, Reference: "data.coder_parameter.region"},
+			},
+			expectParams: map[string][]coderism.UnknownMark{
+				"region": {{Reason: coderism.UnknownParameterValue, Reference: "data.coder_parameter.region"}},
+			},
+		},
+		{
+			name: "impure function",
+			main: `
+				data "coder_parameter" "id" {
+					name    = "id"
+					default = uuid()
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"tag" = data.coder_parameter.id.value
+					}
+				}`,
+			expectTag: []coderism.UnknownMark{
+				{Reason: coderism.UnknownImpureFunction, Reference: "uuid()"},
+			},
+			expectParams: map[string][]coderism.UnknownMark{
+				"id": {{Reason: coderism.UnknownImpureFunction, Reference: "uuid()"}},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			output, _ := coderism.Extract(modules, psr.Files(), coderism.Input{})
+			require.Len(t, output.WorkspaceTags, 1)
+			require.Len(t, output.WorkspaceTags[0].Tags, 1)
+			require.Equal(t, tc.expectTag, output.WorkspaceTags[0].Tags[0].UnknownReasons())
+
+			for _, param := range output.Parameters {
+				require.Equal(t, tc.expectParams[param.Data.Name], param.Value.UnknownReasons(), param.Data.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package coderism
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// validateParameter checks the resolved value of a parameter against its
+// 'option' blocks and 'validation' block. Unknown and null values are not
+// validated, there is nothing to check yet.
+func validateParameter(param Parameter) hcl.Diagnostics {
+	val := param.Value.Value
+	if val == cty.NilVal || !val.IsWhollyKnown() || val.IsNull() {
+		return nil
+	}
+
+	var diags hcl.Diagnostics
+	diags = diags.Extend(validateParameterOptions(param))
+
+	data := param.Data
+	switch {
+	case val.Type() == cty.String && data.ValidationRegex != "":
+		diags = diags.Extend(validateParameterRegex(param))
+	case val.Type() == cty.Number && (data.ValidationMin != nil || data.ValidationMax != nil):
+		diags = diags.Extend(validateParameterRange(param))
+	}
+	return diags
+}
+
+func validateParameterOptions(param Parameter) hcl.Diagnostics {
+	if len(param.Data.Options) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(param.Data.Options))
+	for _, opt := range param.Data.Options {
+		allowed[opt.Value] = true
+	}
+
+	val := param.Value.Value
+	var values []cty.Value
+	if val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType() {
+		values = val.AsValueSlice()
+	} else {
+		values = []cty.Value{val}
+	}
+
+	var diags hcl.Diagnostics
+	for _, v := range values {
+		str, err := CtyValueString(v)
+		if err != nil || !allowed[str] {
+			diags = diags.Append(validationDiagnostic(param, param.Block,
+				validationErrorMessage(param, str, fmt.Sprintf("Value %q is not one of the options of parameter %q.", str, param.Data.Name))))
+		}
+	}
+	return diags
+}
+
+func validateParameterRegex(param Parameter) hcl.Diagnostics {
+	validation := validationBlock(param)
+	regex, err := regexp.Compile(param.Data.ValidationRegex)
+	if err != nil {
+		return hcl.Diagnostics{validationDiagnostic(param, validation,
+			fmt.Sprintf("Invalid validation regex %q: %s", param.Data.ValidationRegex, err.Error()))}
+	}
+
+	str := param.Value.Value.AsString()
+	if regex.MatchString(str) {
+		return nil
+	}
+	return hcl.Diagnostics{validationDiagnostic(param, validation,
+		validationErrorMessage(param, str, fmt.Sprintf("Value %q does not match %q.", str, param.Data.ValidationRegex)))}
+}
+
+func validateParameterRange(param Parameter) hcl.Diagnostics {
+	validation := validationBlock(param)
+	val := param.Value.Value.AsBigFloat()
+	data := param.Data
+
+	if data.ValidationMin != nil && val.Cmp(big.NewFloat(float64(*data.ValidationMin))) < 0 {
+		return hcl.Diagnostics{validationDiagnostic(param, validation,
+			validationErrorMessage(param, val.String(), fmt.Sprintf("Value %s is less than the minimum %d.", val.String(), *data.ValidationMin)))}
+	}
+
+	if data.ValidationMax != nil && val.Cmp(big.NewFloat(float64(*data.ValidationMax))) > 0 {
+		return hcl.Diagnostics{validationDiagnostic(param, validation,
+			validationErrorMessage(param, val.String(), fmt.Sprintf("Value %s is more than the maximum %d.", val.String(), *data.ValidationMax)))}
+	}
+	return nil
+}
+
+// validationErrorMessage returns the custom 'error' of the validation block
+// if one is set. Like the coder provider, {min}, {max} and {value} are
+// replaced in the custom message. The value is the one that is not valid,
+// which is an element of the value of a multi-select parameter.
+func validationErrorMessage(param Parameter, value string, fallback string) string {
+	data := param.Data
+	if data.ValidationError == "" {
+		return fallback
+	}
+
+	r := strings.NewReplacer(
+		"{min}", optionalInt32String(data.ValidationMin),
+		"{max}", optionalInt32String(data.ValidationMax),
+		"{value}", value,
+	)
+	return r.Replace(data.ValidationError)
+}
+
+func optionalInt32String(v *int32) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*v))
+}
+
+// validationBlock returns the 'validation' block of the parameter, or the
+// parameter block itself if it has none.
+func validationBlock(param Parameter) *terraform.Block {
+	blocks := param.Block.GetBlocks("validation")
+	if len(blocks) == 0 {
+		return param.Block
+	}
+	return blocks[0]
+}
+
+func validationDiagnostic(param Parameter, subject *terraform.Block, detail string) *hcl.Diagnostic {
+	r := subject.HCLBlock().DefRange
+	return &hcl.Diagnostic{
+		Severity:    hcl.DiagError,
+		Summary:     fmt.Sprintf("Invalid value for parameter %q", param.Data.Name),
+		Detail:      detail,
+		Subject:     &r,
+		Context:     &param.Block.HCLBlock().DefRange,
+		EvalContext: param.Block.Context().Inner(),
+	}
+}
@@ -0,0 +1,259 @@
+package coderism_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+func Test_ParameterValidation(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		main        string
+		input       coderism.Input
+		expectError string
+		// expectWarnings are the summaries of the warnings.
+		expectWarnings []string
+	}{
+		{
+			name: "valid",
+			main: `
+				data "coder_parameter" "cores" {
+					name    = "cores"
+					type    = "number"
+					default = 4
+					validation {
+						min = 2
+						max = 8
+					}
+				}
+				data "coder_parameter" "name" {
+					name    = "name"
+					default = "abc"
+					validation {
+						regex = "^[a-z]+$"
+					}
+				}`,
+		},
+		{
+			name: "regex mismatch with custom error",
+			main: `
+				data "coder_parameter" "name" {
+					name    = "name"
+					default = "ABC"
+					validation {
+						regex = "^[a-z]+$"
+						error = "{value} must be lowercase"
+					}
+				}`,
+			expectError: "ABC must be lowercase",
+		},
+		{
+			name: "below minimum",
+			main: `
+				data "coder_parameter" "cores" {
+					name    = "cores"
+					type    = "number"
+					default = 1
+					validation {
+						min = 2
+					}
+				}`,
+			expectError: "less than the minimum 2",
+		},
+		{
+			name: "input above maximum",
+			main: `
+				data "coder_parameter" "cores" {
+					name    = "cores"
+					type    = "number"
+					default = 4
+					validation {
+						max   = 8
+						error = "At most {max} cores"
+					}
+				}`,
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "cores", Value: "16"},
+				},
+			},
+			expectError: "At most 8 cores",
+		},
+		{
+			name: "not an option",
+			main: `
+				data "coder_parameter" "region" {
+					name    = "region"
+					default = "ap"
+					option {
+						name  = "US"
+						value = "us"
+					}
+					option {
+						name  = "EU"
+						value = "eu"
+					}
+				}`,
+			expectError: `Value "ap" is not one of the options`,
+		},
+		{
+			name: "list element not an option",
+			main: `
+				data "coder_parameter" "regions" {
+					name    = "regions"
+					type    = "list(string)"
+					default = jsonencode(["us", "ap"])
+					option {
+						name  = "US"
+						value = "us"
+					}
+					option {
+						name  = "EU"
+						value = "eu"
+					}
+				}`,
+			expectError: `Value "ap" is not one of the options`,
+		},
+		{
+			name: "not an option with custom error",
+			main: `
+				data "coder_parameter" "regions" {
+					name    = "regions"
+					type    = "list(string)"
+					default = jsonencode(["us", "ap"])
+					option {
+						name  = "US"
+						value = "us"
+					}
+					validation {
+						error = "{value} is not a region"
+					}
+				}`,
+			expectError: "ap is not a region",
+		},
+		{
+			name: "immutable parameter changed",
+			main: `
+				data "coder_parameter" "region" {
+					name    = "region"
+					default = "us"
+				}`,
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "region", Value: "eu"},
+				},
+				PreviousParameterValues: []*proto.RichParameterValue{
+					{Name: "region", Value: "us"},
+				},
+			},
+			expectError: `Parameter "region" is immutable`,
+		},
+		{
+			name: "immutable parameter reuses previous value",
+			main: `
+				data "coder_parameter" "region" {
+					name    = "region"
+					default = "us"
+				}`,
+			input: coderism.Input{
+				PreviousParameterValues: []*proto.RichParameterValue{
+					{Name: "region", Value: "eu"},
+				},
+			},
+		},
+		{
+			name: "monotonic decrease",
+			main: `
+				data "coder_parameter" "disk" {
+					name    = "disk"
+					type    = "number"
+					mutable = true
+					default = 10
+					validation {
+						monotonic = "increasing"
+					}
+				}`,
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "disk", Value: "5"},
+				},
+				PreviousParameterValues: []*proto.RichParameterValue{
+					{Name: "disk", Value: "20"},
+				},
+			},
+			expectError: "Value must be increasing, but changed from 20 to 5",
+		},
+		{
+			name: "ephemeral parameter reset",
+			main: `
+				data "coder_parameter" "token" {
+					name      = "token"
+					mutable   = true
+					ephemeral = true
+					default   = "none"
+				}`,
+			input: coderism.Input{
+				PreviousParameterValues: []*proto.RichParameterValue{
+					{Name: "token", Value: "abc"},
+				},
+			},
+			expectWarnings: []string{`Ephemeral parameter "token" will be reset`},
+		},
+		{
+			name: "ephemeral parameter given a new value",
+			main: `
+				data "coder_parameter" "token" {
+					name      = "token"
+					mutable   = true
+					ephemeral = true
+					default   = "none"
+				}`,
+			input: coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{
+					{Name: "token", Value: "def"},
+				},
+				PreviousParameterValues: []*proto.RichParameterValue{
+					{Name: "token", Value: "abc"},
+				},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), tc.input, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			_, diags := coderism.Extract(modules, psr.Files(), tc.input)
+			var warnings []string
+			for _, d := range diags {
+				if d.Severity == hcl.DiagWarning {
+					warnings = append(warnings, d.Summary)
+				}
+			}
+			require.Equal(t, tc.expectWarnings, warnings)
+
+			if tc.expectError == "" {
+				require.False(t, diags.HasErrors(), diags.Error())
+				return
+			}
+			require.True(t, diags.HasErrors())
+			require.ErrorContains(t, diags, tc.expectError)
+		})
+	}
+}
@@ -33,12 +33,26 @@ func WorkspaceTags(modules terraform.Modules) (TagBlocks, hcl.Diagnostics) {
 				continue
 			}
 
-			tagObj, ok := tagsAttr.HCLAttribute().Expr.(*hclsyntax.ObjectConsExpr)
-			if !ok {
+			var tags []Tag
+			switch expr := tagsAttr.HCLAttribute().Expr.(type) {
+			case *hclsyntax.ObjectConsExpr:
+				var tagDiags hcl.Diagnostics
+				tags, tagDiags = objectConsTags(expr, evCtx)
+				diags = diags.Extend(tagDiags)
+			case hclsyntax.Expression:
+				// Anything else, like 'merge(local.base, {...})' or
+				// 'local.tags', is evaluated as a whole.
+				var tagDiags hcl.Diagnostics
+				tags, tagDiags = expressionTags(block, expr, evCtx)
+				diags = diags.Extend(tagDiags)
+				if len(tags) == 0 && tagDiags.HasErrors() {
+					continue
+				}
+			default:
 				diags = diags.Append(&hcl.Diagnostic{
 					Severity:    hcl.DiagError,
 					Summary:     "Incorrect type for \"tags\" attribute",
-					Detail:      fmt.Sprintf(`"tags" attribute must be an 'ObjectConsExpr', but got %T`, tagsAttr.HCLAttribute().Expr),
+					Detail:      fmt.Sprintf(`"tags" attribute must be an HCL native syntax expression, but got %T`, tagsAttr.HCLAttribute().Expr),
 					Subject:     &tagsAttr.HCLAttribute().NameRange,
 					Context:     &tagsAttr.HCLAttribute().Range,
 					Expression:  tagsAttr.HCLAttribute().Expr,
@@ -47,37 +61,290 @@ func WorkspaceTags(modules terraform.Modules) (TagBlocks, hcl.Diagnostics) {
 				continue
 			}
 
-			var tags []Tag
-			for _, item := range tagObj.Items {
-				key, kdiags := item.KeyExpr.Value(evCtx)
-				val, vdiags := item.ValueExpr.Value(evCtx)
+			for i := range tags {
+				tags[i].key = markUnknown(tags[i].key, cty.String, tags[i].keyExpr)
+				tags[i].val = markUnknown(tags[i].val, cty.DynamicPseudoType, tags[i].valueExpr)
+			}
 
-				diags = diags.Extend(kdiags)
-				diags = diags.Extend(vdiags)
+			tagBlocks = append(tagBlocks, TagBlock{
+				Tags:          tags,
+				ModuleAddress: moduleAddress(block),
+				block:         block,
+				module:        module,
+			})
+		}
+	}
 
-				if kdiags.HasErrors() {
-					key = cty.UnknownVal(cty.String)
-				}
+	return tagBlocks, diags.Extend(duplicateTags(tagBlocks))
+}
 
-				if vdiags.HasErrors() {
-					val = cty.UnknownVal(cty.NilType)
-				}
+// duplicateTags reports tag keys that are set more than once, in the same
+// block or in different coder_workspace_tags blocks. The last tag in the
+// order of the modules and blocks wins. Setting a different value is an
+// error, because the workspace would be routed to other provisioners than
+// the template author expects.
+func duplicateTags(blocks []TagBlock) hcl.Diagnostics {
+	type source struct {
+		tag   Tag
+		block TagBlock
+	}
 
-				tags = append(tags, Tag{
-					key:       key,
-					val:       val,
-					keyExpr:   item.KeyExpr,
-					valueExpr: item.ValueExpr,
-				})
+	var diags hcl.Diagnostics
+	seen := make(map[string]source)
+	for _, block := range blocks {
+		for _, tag := range block.Tags {
+			if !isKnown(tag.key) || tag.key.IsNull() || tag.key.Type() != cty.String {
+				continue
 			}
-			tagBlocks = append(tagBlocks, TagBlock{
-				Tags:  tags,
-				block: block,
+			key := tag.key.AsString()
+
+			prev, ok := seen[key]
+			seen[key] = source{tag: tag, block: block}
+			if !ok {
+				continue
+			}
+
+			severity := hcl.DiagWarning
+			summary := "Duplicate workspace tag"
+			if isKnown(prev.tag.val) && isKnown(tag.val) && !sameTagValue(prev.tag.val, tag.val) {
+				severity = hcl.DiagError
+				summary = "Conflicting workspace tag"
+			}
+
+			prevRange := prev.tag.keyExpr.Range()
+			r := tag.keyExpr.Range()
+			diag := &hcl.Diagnostic{
+				Severity:    severity,
+				Summary:     summary,
+				Detail:      fmt.Sprintf("The tag %q is already set at %s. The last value is used.", key, prevRange.String()),
+				Subject:     &r,
+				Expression:  tag.keyExpr,
+				EvalContext: block.block.Context().Inner(),
+			}
+			if prevRange.Filename == r.Filename {
+				// Cover both keys, so the earlier one is shown too.
+				context := hcl.RangeOver(prevRange, r)
+				diag.Context = &context
+			}
+			diags = diags.Append(diag)
+		}
+	}
+	return diags
+}
+
+// sameTagValue compares two known tag values by their string form, like
+// they are sent to the provisioner.
+func sameTagValue(a, b cty.Value) bool {
+	if a.IsNull() || b.IsNull() {
+		return a.IsNull() && b.IsNull()
+	}
+	aStr, aErr := CtyValueString(a)
+	bStr, bErr := CtyValueString(b)
+	return aErr == nil && bErr == nil && aStr == bStr
+}
+
+func objectConsTags(obj *hclsyntax.ObjectConsExpr, evCtx *hcl.EvalContext) ([]Tag, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var tags []Tag
+	for _, item := range obj.Items {
+		key, kdiags := item.KeyExpr.Value(evCtx)
+		val, vdiags := item.ValueExpr.Value(evCtx)
+
+		diags = diags.Extend(kdiags)
+		diags = diags.Extend(vdiags)
+
+		if kdiags.HasErrors() {
+			key = cty.UnknownVal(cty.String)
+		}
+
+		if vdiags.HasErrors() {
+			val = cty.UnknownVal(cty.NilType)
+		}
+
+		tags = append(tags, Tag{
+			key:       key,
+			val:       val,
+			keyExpr:   item.KeyExpr,
+			valueExpr: item.ValueExpr,
+		})
+	}
+	return tags, diags
+}
+
+// expressionTags evaluates the whole "tags" expression, and splits the
+// resulting object or map into tags. Each tag keeps the expression that
+// produced its key when it can be traced, otherwise the tag points to the
+// whole expression.
+func expressionTags(block *terraform.Block, expr hclsyntax.Expression, evCtx *hcl.EvalContext) ([]Tag, hcl.Diagnostics) {
+	val, diags := expr.Value(evCtx)
+	if diags.HasErrors() {
+		// A 'merge' can still be split by its arguments, so one bad
+		// argument does not hide the tags of the others.
+		tags, ok := mergeTags(expr, evCtx)
+		if !ok {
+			return nil, diags
+		}
+		return tags, diags
+	}
+
+	if !val.IsKnown() {
+		// The keys are not known, so the tags cannot be split.
+		return []Tag{{
+			key:       cty.UnknownVal(cty.String),
+			val:       cty.UnknownVal(cty.NilType),
+			keyExpr:   expr,
+			valueExpr: expr,
+		}}, diags
+	}
+
+	ty := val.Type()
+	if val.IsNull() || !(ty.IsObjectType() || ty.IsMapType()) {
+		r := expr.Range()
+		return nil, diags.Append(&hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Incorrect type for \"tags\" attribute",
+			Detail:      fmt.Sprintf(`"tags" attribute must evaluate to an object or map, but got %s`, ty.FriendlyName()),
+			Subject:     &r,
+			Expression:  expr,
+			EvalContext: block.Context().Inner(),
+		})
+	}
+
+	sources := tagSources(expr, evCtx)
+	var tags []Tag
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		src, ok := sources[k.AsString()]
+		if !ok {
+			src = tagSource{keyExpr: literalExpr(k, expr.Range()), valueExpr: expr}
+		}
+		tags = append(tags, Tag{
+			key:       k,
+			val:       v,
+			keyExpr:   src.keyExpr,
+			valueExpr: src.valueExpr,
+		})
+	}
+	return tags, diags
+}
+
+// mergeTags splits a 'merge' call into the tags of each argument. Arguments
+// that fail to evaluate are unknown, like the values of object literals.
+// The last argument with a key wins just like 'merge'.
+func mergeTags(expr hclsyntax.Expression, evCtx *hcl.EvalContext) ([]Tag, bool) {
+	if paren, ok := expr.(*hclsyntax.ParenthesesExpr); ok {
+		return mergeTags(paren.Expression, evCtx)
+	}
+	call, ok := expr.(*hclsyntax.FunctionCallExpr)
+	if !ok || call.Name != "merge" {
+		return nil, false
+	}
+
+	var tags []Tag
+	for _, arg := range call.Args {
+		if obj, ok := arg.(*hclsyntax.ObjectConsExpr); ok {
+			// Diagnostics are already reported by the whole expression.
+			argTags, _ := objectConsTags(obj, evCtx)
+			tags = append(tags, argTags...)
+			continue
+		}
+		if argTags, ok := mergeTags(arg, evCtx); ok {
+			tags = append(tags, argTags...)
+			continue
+		}
+
+		val, diags := arg.Value(evCtx)
+		if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.CanIterateElements() {
+			tags = append(tags, Tag{
+				key:       cty.UnknownVal(cty.String),
+				val:       cty.UnknownVal(cty.NilType),
+				keyExpr:   arg,
+				valueExpr: arg,
+			})
+			continue
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			tags = append(tags, Tag{
+				key:       k,
+				val:       v,
+				keyExpr:   literalExpr(k, arg.Range()),
+				valueExpr: arg,
 			})
 		}
 	}
 
-	return tagBlocks, diags
+	// Drop the tags that are overridden by a later argument.
+	last := make(map[string]int)
+	for i, tag := range tags {
+		if tag.key.IsKnown() && !tag.key.IsNull() && tag.key.Type() == cty.String {
+			last[tag.key.AsString()] = i
+		}
+	}
+	merged := make([]Tag, 0, len(tags))
+	for i, tag := range tags {
+		if tag.key.IsKnown() && !tag.key.IsNull() && tag.key.Type() == cty.String && last[tag.key.AsString()] != i {
+			continue
+		}
+		merged = append(merged, tag)
+	}
+	return merged, true
+}
+
+type tagSource struct {
+	keyExpr   hclsyntax.Expression
+	valueExpr hclsyntax.Expression
+}
+
+// tagSources finds the expressions that produce each key. Object literal
+// items are used as is. 'merge' arguments are followed, and the last
+// argument with a key wins just like 'merge'.
+func tagSources(expr hclsyntax.Expression, evCtx *hcl.EvalContext) map[string]tagSource {
+	sources := make(map[string]tagSource)
+	switch e := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			key, diags := item.KeyExpr.Value(evCtx)
+			if diags.HasErrors() || !key.IsKnown() || key.IsNull() || key.Type() != cty.String {
+				continue
+			}
+			sources[key.AsString()] = tagSource{keyExpr: item.KeyExpr, valueExpr: item.ValueExpr}
+		}
+	case *hclsyntax.FunctionCallExpr:
+		if e.Name != "merge" {
+			break
+		}
+		for _, arg := range e.Args {
+			argSources := tagSources(arg, evCtx)
+			if len(argSources) == 0 {
+				// Not a literal, so the keys come from the value.
+				val, diags := arg.Value(evCtx)
+				if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.CanIterateElements() {
+					continue
+				}
+				for it := val.ElementIterator(); it.Next(); {
+					k, _ := it.Element()
+					if k.Type() != cty.String {
+						continue
+					}
+					argSources[k.AsString()] = tagSource{keyExpr: literalExpr(k, arg.Range()), valueExpr: arg}
+				}
+			}
+			for k, src := range argSources {
+				sources[k] = src
+			}
+		}
+	case *hclsyntax.ParenthesesExpr:
+		return tagSources(e.Expression, evCtx)
+	}
+	return sources
+}
+
+func literalExpr(val cty.Value, r hcl.Range) hclsyntax.Expression {
+	return &hclsyntax.LiteralValueExpr{
+		Val:      val,
+		SrcRange: r,
+	}
 }
 
 type TagBlocks []TagBlock
@@ -90,7 +357,8 @@ func (t TagBlocks) ValidTags() (map[string]string, error) {
 			return nil, fmt.Errorf("block %q: %w", block.block.Label(), err)
 		}
 		for k, v := range valid {
-			// TODO: What about tags overriding each other?
+			// The last block wins. Duplicate keys are reported by
+			// WorkspaceTags.
 			tags[k] = v
 		}
 	}
@@ -108,8 +376,12 @@ func (t TagBlocks) Unknowns() []string {
 }
 
 type TagBlock struct {
-	Tags  []Tag
-	block *terraform.Block
+	Tags []Tag
+	// ModuleAddress is the module that declares the block, like
+	// 'module.code-server[0]'. It is empty for the root module.
+	ModuleAddress string
+	block         *terraform.Block
+	module        *terraform.Module
 }
 
 func (t TagBlock) AllReferences() []*terraform.Reference {
@@ -117,12 +389,13 @@ func (t TagBlock) AllReferences() []*terraform.Reference {
 }
 
 // ValidTags returns the valid set of 'key=value' tags that are valid.
-// Valid tags require that the value is statically known.
+// Valid tags require that the value is statically known. If a key is set
+// twice, the last one wins.
 func (t TagBlock) ValidTags() (map[string]string, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 	known := make(map[string]string)
 	for _, tag := range t.Tags {
-		if !tag.val.IsWhollyKnown() || !tag.val.IsWhollyKnown() {
+		if !isKnown(tag.key) || !isKnown(tag.val) {
 			continue
 		}
 
@@ -144,13 +417,13 @@ func (t TagBlock) ValidTags() (map[string]string, hcl.Diagnostics) {
 func (t TagBlock) Unknowns() []string {
 	var unknowns []string
 	for _, tag := range t.Tags {
-		if !tag.key.IsWhollyKnown() {
+		if !isKnown(tag.key) {
 			// TODO: improve this
 			unknowns = append(unknowns, "???")
 			continue
 		}
 
-		if !tag.val.IsWhollyKnown() {
+		if !isKnown(tag.val) {
 			keyStr, err := CtyValueString(tag.key)
 			if err != nil {
 				unknowns = append(unknowns, "?ERR?")
@@ -171,7 +444,18 @@ type Tag struct {
 }
 
 func (tag Tag) IsKnown() bool {
-	return tag.key.IsWhollyKnown() && tag.val.IsWhollyKnown()
+	return isKnown(tag.key) && isKnown(tag.val)
+}
+
+// KeyIsKnown is true if the key is known, even if the value is not.
+func (tag Tag) KeyIsKnown() bool {
+	return isKnown(tag.key)
+}
+
+// UnknownReasons returns why the key or value of the tag is not known. Known
+// tags have no reasons.
+func (tag Tag) UnknownReasons() []UnknownMark {
+	return append(UnknownMarks(tag.key), UnknownMarks(tag.val)...)
 }
 
 func (tag Tag) References() []string {
@@ -198,7 +482,7 @@ func (tag Tag) EvalToString(tb TagBlock) (string, string, hcl.Diagnostics) {
 		diags = diags.Append(&hcl.Diagnostic{
 			Severity:    hcl.DiagError,
 			Summary:     "Tag key is not known",
-			Detail:      "Tag must be resolvable",
+			Detail:      unknownDetail("Tag must be resolvable", tag.key),
 			Subject:     &r,
 			Expression:  tag.keyExpr,
 			EvalContext: tb.block.Context().Inner(),
@@ -211,7 +495,7 @@ func (tag Tag) EvalToString(tb TagBlock) (string, string, hcl.Diagnostics) {
 		diags = diags.Append(&hcl.Diagnostic{
 			Severity:    hcl.DiagError,
 			Summary:     "Tag value is not known",
-			Detail:      "Tag must be resolvable",
+			Detail:      unknownDetail("Tag must be resolvable", tag.val),
 			Subject:     &r,
 			Expression:  tag.valueExpr,
 			EvalContext: tb.block.Context().Inner(),
@@ -219,3 +503,10 @@ func (tag Tag) EvalToString(tb TagBlock) (string, string, hcl.Diagnostics) {
 	}
 	return keyStr, valStr, diags
 }
+
+// isKnown is like IsWhollyKnown, but cty.NilVal is not known either. The
+// evaluation sets cty.NilVal for expressions that fail, like a reference to
+// an attribute that is only known after apply.
+func isKnown(val cty.Value) bool {
+	return val != cty.NilVal && val.IsWhollyKnown()
+}
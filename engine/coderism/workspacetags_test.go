@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 
@@ -613,10 +614,10 @@ func Test_WorkspaceTags(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
 			require.NoError(t, err)
 
-			output, err := coderism.Extract(modules, coderism.Input{})
+			output, err := coderism.Extract(modules, psr.Files(), coderism.Input{})
 			if tc.expectError != "" {
 				require.ErrorContains(t, err, tc.expectError)
 				return
@@ -632,3 +633,220 @@ func Test_WorkspaceTags(t *testing.T) {
 		})
 	}
 }
+
+func Test_WorkspaceTagsExpressions(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name           string
+		main           string
+		expectTags     map[string]string
+		expectUnknowns []string
+		expectRefs     map[string][]string
+		expectError    string
+	}{
+		{
+			name: "merge",
+			main: `
+				locals {
+					base = {
+						"os" = "linux"
+					}
+				}
+				variable "region" {
+					default = "us"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = merge(local.base, {
+						"region" = var.region
+					})
+				}`,
+			expectTags: map[string]string{
+				"os":     "linux",
+				"region": "us",
+			},
+			expectRefs: map[string][]string{
+				"os":     {"local.base"},
+				"region": {"var.region"},
+			},
+		},
+		{
+			name: "local",
+			main: `
+				locals {
+					tags = {
+						"os" = "linux"
+					}
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = local.tags
+				}`,
+			expectTags: map[string]string{
+				"os": "linux",
+			},
+		},
+		{
+			name: "for expression",
+			main: `
+				variable "zones" {
+					default = ["a", "b"]
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = { for z in var.zones : "zone-${z}" => z }
+				}`,
+			expectTags: map[string]string{
+				"zone-a": "a",
+				"zone-b": "b",
+			},
+		},
+		{
+			name: "unknown value",
+			main: `
+				resource "docker_image" "main" {
+					name = "ubuntu"
+				}
+				data "coder_workspace_tags" "tags" {
+					tags = merge({
+						"os" = "linux"
+					}, {
+						"digest" = docker_image.main.repo_digest
+					})
+				}`,
+			expectTags: map[string]string{
+				"os": "linux",
+			},
+			expectUnknowns: []string{"digest"},
+		},
+		{
+			name: "not an object",
+			main: `
+				data "coder_workspace_tags" "tags" {
+					tags = ["os"]
+				}`,
+			expectError: "must evaluate to an object or map",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			tags, diags := coderism.WorkspaceTags(modules)
+			if tc.expectError != "" {
+				require.ErrorContains(t, diags, tc.expectError)
+				return
+			}
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			valid, err := tags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectTags, valid)
+
+			if tc.expectUnknowns == nil {
+				tc.expectUnknowns = []string{}
+			}
+			require.ElementsMatch(t, tc.expectUnknowns, tags.Unknowns())
+
+			for _, tb := range tags {
+				for _, tag := range tb.Tags {
+					refs, ok := tc.expectRefs[tag.SafeKeyString()]
+					if !ok {
+						continue
+					}
+					require.ElementsMatch(t, refs, tag.References())
+				}
+			}
+		})
+	}
+}
+
+func Test_WorkspaceTagsDuplicates(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		main        string
+		expectTags  map[string]string
+		expectError string
+		expectWarn  string
+	}{
+		{
+			name: "conflict across blocks",
+			main: `
+				data "coder_workspace_tags" "first" {
+					tags = {
+						"zone" = "us"
+					}
+				}
+				data "coder_workspace_tags" "second" {
+					tags = {
+						"zone" = "eu"
+					}
+				}`,
+			expectTags:  map[string]string{"zone": "eu"},
+			expectError: `Conflicting workspace tag; The tag "zone" is already set at main.tf:4,7-13`,
+		},
+		{
+			name: "conflict in one block",
+			main: `
+				data "coder_workspace_tags" "tags" {
+					tags = {
+						"zone" = "us"
+						"zone" = "eu"
+					}
+				}`,
+			expectTags:  map[string]string{"zone": "eu"},
+			expectError: "Conflicting workspace tag",
+		},
+		{
+			name: "same value",
+			main: `
+				data "coder_workspace_tags" "first" {
+					tags = {
+						"zone" = "us"
+					}
+				}
+				data "coder_workspace_tags" "second" {
+					tags = {
+						"zone" = "us"
+					}
+				}`,
+			expectTags: map[string]string{"zone": "us"},
+			expectWarn: "Duplicate workspace tag",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(tc.main), 0644)
+			require.NoError(t, err)
+
+			_, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.NoError(t, err)
+
+			tags, diags := coderism.WorkspaceTags(modules)
+			if tc.expectError != "" {
+				require.ErrorContains(t, diags, tc.expectError)
+			} else {
+				require.False(t, diags.HasErrors(), diags.Error())
+			}
+			if tc.expectWarn != "" {
+				require.Len(t, diags, 1)
+				require.Equal(t, hcl.DiagWarning, diags[0].Severity)
+				require.Equal(t, tc.expectWarn, diags[0].Summary)
+			}
+
+			valid, err := tags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectTags, valid)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func ReferenceNames(exp hcl.Expression) []string {
@@ -29,7 +30,14 @@ func CreateDotReferenceFromTraversal(traversals ...hcl.Traversal) string {
 			case hcl.TraverseAttr:
 				refParts = append(refParts, part.Name)
 			case hcl.TraverseIndex:
-				refParts = append(refParts, fmt.Sprintf("[%s]", part.Key.AsString()))
+				switch {
+				case part.Key.Type() == cty.String:
+					refParts = append(refParts, fmt.Sprintf("[%s]", part.Key.AsString()))
+				case part.Key.Type() == cty.Number:
+					refParts = append(refParts, fmt.Sprintf("[%s]", part.Key.AsBigFloat().String()))
+				default:
+					refParts = append(refParts, "[?]")
+				}
 			}
 		}
 	}
@@ -5,6 +5,8 @@ import (
 	"github.com/hashicorp/hcl/v2/ext/typeexpr"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 func DecodeVarType(exp hcl.Expression) (cty.Type, *typeexpr.Defaults, error) {
@@ -15,15 +17,14 @@ func DecodeVarType(exp hcl.Expression) (cty.Type, *typeexpr.Defaults, error) {
 		if lit, ok := tpl.Parts[0].(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String {
 			keyword := lit.Val.AsString()
 
-			exp = &hclsyntax.ScopeTraversalExpr{
-				Traversal: []hcl.Traverser{
-					hcl.TraverseRoot{
-						Name:     keyword,
-						SrcRange: exp.Range(),
-					},
-				},
-				SrcRange: exp.Range(),
+			// The literal can be a full type expression, like "list(string)",
+			// so parse it rather than treating it as a single keyword.
+			r := exp.Range()
+			parsed, diags := hclsyntax.ParseExpression([]byte(keyword), r.Filename, r.Start)
+			if diags.HasErrors() {
+				return cty.NilType, nil, diags
 			}
+			exp = parsed
 		}
 	}
 
@@ -41,3 +42,18 @@ func DecodeVarType(exp hcl.Expression) (cty.Type, *typeexpr.Defaults, error) {
 	}
 	return t, def, nil
 }
+
+// ParseValue converts a raw string value, as sent by coderd, into a value of
+// the given type. Primitive types are converted from their string form.
+// Collection and structural types, like list(string), are JSON encoded.
+func ParseValue(ty cty.Type, raw string) (cty.Value, error) {
+	if ty == cty.DynamicPseudoType || ty == cty.NilType {
+		return cty.StringVal(raw), nil
+	}
+
+	if ty.IsPrimitiveType() {
+		return convert.Convert(cty.StringVal(raw), ty)
+	}
+
+	return ctyjson.Unmarshal([]byte(raw), ty)
+}
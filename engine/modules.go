@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ModuleManifestPath is where 'terraform init' records the modules it
+// downloaded. The parser loads modules from it without downloading them.
+const ModuleManifestPath = ".terraform/modules/modules.json"
+
+// maxModuleDepth stops the walk of module calls that call each other.
+const maxModuleDepth = 10
+
+// ModuleRecord is an entry of the module manifest. Key is the path of the
+// module call, like 'app.network', and Dir is relative to the root module.
+type ModuleRecord struct {
+	Key     string `json:"Key"`
+	Source  string `json:"Source"`
+	Version string `json:"Version,omitempty"`
+	Dir     string `json:"Dir"`
+}
+
+type ModuleManifest struct {
+	Modules []ModuleRecord `json:"Modules"`
+}
+
+// Record returns the module downloaded for the module call.
+func (m ModuleManifest) Record(key string) (ModuleRecord, bool) {
+	for _, record := range m.Modules {
+		if record.Key == key {
+			return record, true
+		}
+	}
+	return ModuleRecord{}, false
+}
+
+// WithModuleCache adds the modules of a module cache to the template
+// directory, as if 'terraform init' had downloaded them. The cache has a
+// directory per source and version, like
+// 'registry.coder.com/modules/code-server/coder/1.0.26', and a version
+// constraint like '~> 1.0' uses the newest cached version that meets it.
+// Modules already in '.terraform/modules' are used as they are.
+//
+// Pass the returned directory to ParseTerraform.
+func WithModuleCache(dir fs.FS, cache fs.FS) (fs.FS, error) {
+	if cache == nil {
+		return dir, nil
+	}
+
+	manifest, err := ReadModuleManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]ModuleRecord, len(manifest.Modules))
+	for _, record := range manifest.Modules {
+		existing[record.Key] = record
+	}
+
+	layer := afero.NewMemMapFs()
+	added := 0
+
+	// walk adds the modules called by the module in moduleDir. Modules in
+	// the manifest are in the template directory, cached modules in the
+	// cache.
+	var walk func(fsys fs.FS, moduleDir string, key string, depth int) error
+	walk = func(fsys fs.FS, moduleDir string, key string, depth int) error {
+		if depth > maxModuleDepth {
+			return nil
+		}
+		for _, call := range moduleCalls(fsys, moduleDir) {
+			callKey := call.name
+			if key != "" {
+				callKey = key + "." + call.name
+			}
+
+			if record, ok := existing[callKey]; ok {
+				if err := walk(dir, path.Clean(record.Dir), callKey, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if isLocalSource(call.source) {
+				if err := walk(fsys, path.Join(moduleDir, call.source), callKey, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+
+			pkg, subdir, version, ok := moduleCachePath(cache, call.source, call.version)
+			if !ok {
+				// Not in the cache, the parser reports the module.
+				continue
+			}
+
+			target := path.Join(".terraform/modules", callKey)
+			if err := copyModule(layer, cache, pkg, target); err != nil {
+				return fmt.Errorf("copy module %q: %w", callKey, err)
+			}
+			record := ModuleRecord{
+				Key:     callKey,
+				Source:  call.source,
+				Version: version,
+				Dir:     path.Join(target, subdir),
+			}
+			manifest.Modules = append(manifest.Modules, record)
+			existing[callKey] = record
+			added++
+
+			if err := walk(cache, path.Join(pkg, subdir), callKey, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = walk(dir, ".", "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if added == 0 {
+		return dir, nil
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("marshal module manifest: %w", err)
+	}
+	err = afero.WriteFile(layer, ModuleManifestPath, data, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("write module manifest: %w", err)
+	}
+
+	base := afero.NewReadOnlyFs(afero.FromIOFS{FS: dir})
+	return afero.NewIOFS(afero.NewCopyOnWriteFs(base, layer)), nil
+}
+
+// ReadModuleManifest reads the modules 'terraform init' downloaded into the
+// template directory. Without a manifest, only the root module is in it.
+func ReadModuleManifest(dir fs.FS) (ModuleManifest, error) {
+	var manifest ModuleManifest
+	data, err := fs.ReadFile(dir, ModuleManifestPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Like 'terraform init', start with the root module.
+			manifest.Modules = []ModuleRecord{{Key: "", Source: "", Dir: "."}}
+			return manifest, nil
+		}
+		return manifest, fmt.Errorf("read module manifest: %w", err)
+	}
+
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("unmarshal module manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+type moduleCall struct {
+	name    string
+	source  string
+	version string
+}
+
+var moduleCallSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "module", LabelNames: []string{"name"}},
+	},
+}
+
+var moduleSourceSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "source"},
+		{Name: "version"},
+	},
+}
+
+// moduleCalls finds the module blocks of the module in the directory. Like
+// terraform, the source and version must be literal strings. Files that do
+// not parse are left to the parser to report.
+func moduleCalls(fsys fs.FS, dir string) []moduleCall {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil
+	}
+
+	p := hclparse.NewParser()
+	var calls []moduleCall
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filename := path.Join(dir, entry.Name())
+		var parse func([]byte, string) (*hcl.File, hcl.Diagnostics)
+		switch {
+		case strings.HasSuffix(entry.Name(), ".tf"):
+			parse = p.ParseHCL
+		case strings.HasSuffix(entry.Name(), ".tf.json"):
+			parse = p.ParseJSON
+		default:
+			continue
+		}
+
+		src, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			continue
+		}
+		file, diags := parse(src, filename)
+		if diags.HasErrors() {
+			continue
+		}
+
+		content, _, _ := file.Body.PartialContent(moduleCallSchema)
+		for _, block := range content.Blocks {
+			attrs, _, _ := block.Body.PartialContent(moduleSourceSchema)
+			call := moduleCall{name: block.Labels[0]}
+			call.source = literalString(attrs.Attributes["source"])
+			call.version = literalString(attrs.Attributes["version"])
+			if call.source != "" {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+func literalString(attr *hcl.Attribute) string {
+	if attr == nil {
+		return ""
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
+
+func isLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// moduleCachePath is the directory of a module source and version in the
+// module cache, the directory of the module in it, and the version. Forced
+// getters, schemes and query strings are not part of the path, and the ref
+// of a git source is its version, so
+// 'git::https://example.com/modules.git//vpc?ref=v1.0.0' is
+// 'example.com/modules.git/v1.0.0' with the subdirectory 'vpc'. The version
+// of a registry module is a constraint, like '~> 1.0', which is resolved to
+// the newest cached version that meets it.
+func moduleCachePath(cache fs.FS, source, constraint string) (string, string, string, bool) {
+	if _, rest, ok := strings.Cut(source, "::"); ok {
+		source = rest
+	}
+	if _, rest, ok := strings.Cut(source, "://"); ok {
+		source = rest
+	}
+	source, query, _ := strings.Cut(source, "?")
+	pkg, subdir, _ := strings.Cut(source, "//")
+	if !fs.ValidPath(pkg) || (subdir != "" && !fs.ValidPath(subdir)) {
+		return "", "", "", false
+	}
+
+	var ver string
+	if constraint != "" {
+		var ok bool
+		ver, ok = cachedVersion(cache, pkg, constraint)
+		if !ok {
+			return "", "", "", false
+		}
+	} else if q, err := url.ParseQuery(query); err == nil {
+		ver = q.Get("ref")
+	}
+
+	pkg = path.Join(pkg, ver)
+	if !fs.ValidPath(pkg) {
+		return "", "", "", false
+	}
+	if info, err := fs.Stat(cache, pkg); err != nil || !info.IsDir() {
+		return "", "", "", false
+	}
+	return pkg, subdir, ver, true
+}
+
+// cachedVersion is the newest version directory of the cached module that
+// meets the version constraint. Directories that are not versions are
+// skipped.
+func cachedVersion(cache fs.FS, pkg, constraint string) (string, bool) {
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return "", false
+	}
+	entries, err := fs.ReadDir(cache, pkg)
+	if err != nil {
+		return "", false
+	}
+
+	var newest *version.Version
+	var name string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		v, err := version.NewVersion(entry.Name())
+		if err != nil || !constraints.Check(v) {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest, name = v, entry.Name()
+		}
+	}
+	return name, newest != nil
+}
+
+// copyModule copies the files of a cached module into the layer, so the
+// parser finds it where 'terraform init' would have put it.
+func copyModule(layer afero.Fs, cache fs.FS, src, target string) error {
+	return fs.WalkDir(cache, src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, src), "/")
+		dest := path.Join(target, rel)
+		if d.IsDir() {
+			return layer.MkdirAll(dest, 0o755)
+		}
+
+		data, err := fs.ReadFile(cache, name)
+		if err != nil {
+			return err
+		}
+		return afero.WriteFile(layer, dest, data, 0o644)
+	})
+}
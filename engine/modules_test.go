@@ -0,0 +1,230 @@
+package engine_test
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+const modulesMain = `
+data "coder_workspace" "me" {}
+
+module "code-server" {
+	count    = data.coder_workspace.me.start_count
+	source   = "registry.coder.com/modules/code-server/coder"
+	version  = "1.0.26"
+	agent_id = "agent"
+}
+
+module "dotfiles" {
+	source = "git::https://example.com/dotfiles.git//module?ref=v2"
+}
+`
+
+func codeServerModule(port string) string {
+	return `
+variable "agent_id" {
+	type = string
+}
+
+data "coder_parameter" "folder" {
+	name    = "folder"
+	default = "/home/coder"
+}
+
+module "settings" {
+	source = "./settings"
+}
+
+data "coder_workspace_tags" "tags" {
+	tags = {
+		"port" = "` + port + `"
+	}
+}
+`
+}
+
+const settingsModule = `
+data "coder_parameter" "theme" {
+	name    = "theme"
+	default = "dark"
+}
+`
+
+const dotfilesModule = `
+data "coder_parameter" "dotfiles_uri" {
+	name    = "dotfiles_uri"
+	default = ""
+}
+`
+
+func TestWithModuleCache(t *testing.T) {
+	t.Parallel()
+
+	const codeServerSource = "registry.coder.com/modules/code-server/coder"
+	const codeServer = codeServerSource + "/1.0.26"
+	cache := map[string]string{
+		codeServer + "/main.tf":                      codeServerModule("13337"),
+		codeServer + "/settings/main.tf":             settingsModule,
+		"example.com/dotfiles.git/v2/module/main.tf": dotfilesModule,
+		"example.com/dotfiles.git/v2/README.md":      "# dotfiles",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		cache    map[string]string
+		params   []string
+		tags     map[string]string
+		warnings int
+	}{
+		{
+			name:     "no cache",
+			files:    map[string]string{"main.tf": modulesMain},
+			params:   []string{},
+			tags:     map[string]string{},
+			warnings: 2,
+		},
+		{
+			name:   "cache",
+			files:  map[string]string{"main.tf": modulesMain},
+			cache:  cache,
+			params: []string{"dotfiles_uri", "folder", "theme"},
+			tags:   map[string]string{"port": "13337"},
+		},
+		{
+			name: "partial cache",
+			files: map[string]string{
+				"main.tf": modulesMain,
+			},
+			cache: map[string]string{
+				codeServer + "/main.tf":          codeServerModule("13337"),
+				codeServer + "/settings/main.tf": settingsModule,
+			},
+			params:   []string{"folder", "theme"},
+			tags:     map[string]string{"port": "13337"},
+			warnings: 1,
+		},
+		{
+			// The newest cached version that meets the constraint is used.
+			name: "version constraint",
+			files: map[string]string{
+				"main.tf": strings.Replace(modulesMain, `"1.0.26"`, `"~> 1.0"`, 1),
+			},
+			cache: map[string]string{
+				codeServer + "/main.tf":                      codeServerModule("13337"),
+				codeServer + "/settings/main.tf":             settingsModule,
+				codeServerSource + "/1.2.0/main.tf":          codeServerModule("8080"),
+				codeServerSource + "/1.2.0/settings/main.tf": settingsModule,
+				codeServerSource + "/2.0.0/main.tf":          codeServerModule("9090"),
+				codeServerSource + "/2.0.0/settings/main.tf": settingsModule,
+				"example.com/dotfiles.git/v2/module/main.tf": dotfilesModule,
+			},
+			params: []string{"dotfiles_uri", "folder", "theme"},
+			tags:   map[string]string{"port": "8080"},
+		},
+		{
+			name: "version constraint not cached",
+			files: map[string]string{
+				"main.tf": strings.Replace(modulesMain, `"1.0.26"`, `">= 3.0"`, 1),
+			},
+			cache:    cache,
+			params:   []string{"dotfiles_uri"},
+			tags:     map[string]string{},
+			warnings: 1,
+		},
+		{
+			// Modules downloaded by 'terraform init' win over the cache.
+			name: "terraform init",
+			files: map[string]string{
+				"main.tf": modulesMain,
+				".terraform/modules/modules.json": `{"Modules": [
+					{"Key": "", "Source": "", "Dir": "."},
+					{"Key": "code-server", "Source": "registry.coder.com/modules/code-server/coder", "Version": "1.0.26", "Dir": ".terraform/modules/code-server"},
+					{"Key": "code-server.settings", "Source": "./settings", "Dir": ".terraform/modules/code-server/settings"}
+				]}`,
+				".terraform/modules/code-server/main.tf":          codeServerModule("8080"),
+				".terraform/modules/code-server/settings/main.tf": settingsModule,
+			},
+			cache:  cache,
+			params: []string{"dotfiles_uri", "folder", "theme"},
+			tags:   map[string]string{"port": "8080"},
+		},
+		{
+			// Loaded modules that declare nothing are not reported.
+			name: "modules without blocks",
+			files: map[string]string{
+				"main.tf": modulesMain + `
+module "empty" {
+	source = "./empty"
+}
+`,
+				"empty/main.tf": "# Nothing yet.\n",
+				".terraform/modules/modules.json": `{"Modules": [
+					{"Key": "", "Source": "", "Dir": "."},
+					{"Key": "dotfiles", "Source": "git::https://example.com/dotfiles.git//module?ref=v2", "Dir": ".terraform/modules/dotfiles/module"}
+				]}`,
+				".terraform/modules/dotfiles/module/main.tf": "# Nothing yet.\n",
+			},
+			params:   []string{},
+			tags:     map[string]string{},
+			warnings: 1,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			for name, src := range tc.files {
+				err := afero.WriteFile(memfs, name, []byte(src), 0644)
+				require.NoError(t, err)
+			}
+			var dir fs.FS = afero.NewIOFS(memfs)
+
+			if tc.cache != nil {
+				cachefs := afero.NewMemMapFs()
+				for name, src := range tc.cache {
+					err := afero.WriteFile(cachefs, name, []byte(src), 0644)
+					require.NoError(t, err)
+				}
+
+				withCache, err := engine.WithModuleCache(dir, afero.NewIOFS(cachefs))
+				require.NoError(t, err)
+				dir = withCache
+			}
+
+			input := coderism.Input{}
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), input, dir)
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), input)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			params := []string{}
+			for _, param := range output.Parameters {
+				params = append(params, param.Data.Name)
+			}
+			require.ElementsMatch(t, tc.params, params)
+
+			tags, err := output.WorkspaceTags.ValidTags()
+			require.NoError(t, err)
+			require.Equal(t, tc.tags, tags)
+
+			warnings := 0
+			for _, diag := range diags {
+				if diag.Summary == "Module not loaded" {
+					warnings++
+				}
+			}
+			require.Equal(t, tc.warnings, warnings)
+		})
+	}
+}
@@ -8,7 +8,7 @@ import (
 
 	"github.com/aquasecurity/trivy/pkg/iac/scanners/terraform/parser"
 	"github.com/aquasecurity/trivy/pkg/iac/terraform"
-	"github.com/hashicorp/hcl/v2"
+	tfcontext "github.com/aquasecurity/trivy/pkg/iac/terraform/context"
 	"github.com/zclconf/go-cty/cty"
 
 	"github.com/coder/terraform-eval/engine/coderism"
@@ -20,8 +20,19 @@ func ParseTerraform(ctx context.Context, input coderism.Input, dir fs.FS) (*pars
 		return nil, nil, cty.NilVal, fmt.Errorf("find tfvars files: %w", err)
 	}
 
-	diags := make(hcl.Diagnostics, 0)
-	hook := coderism.ParameterContextsEvalHook(input, diags)
+	// The data sources are set first, so parameters can reference them.
+	dataHook := coderism.DataSourcesEvalHook(input)
+	stateHook := coderism.StateEvalHook(input)
+	fixturesHook := coderism.DataFixturesEvalHook(input)
+	unknownsHook := coderism.UnknownsEvalHook()
+	paramHook := coderism.ParameterContextsEvalHook(input)
+	hook := func(ctx *tfcontext.Context, blocks terraform.Blocks, inputVars map[string]cty.Value) {
+		dataHook(ctx, blocks, inputVars)
+		stateHook(ctx, blocks, inputVars)
+		fixturesHook(ctx, blocks, inputVars)
+		unknownsHook(ctx, blocks, inputVars)
+		paramHook(ctx, blocks, inputVars)
+	}
 	// moduleSource is "" for a local module
 	p := parser.New(dir, "",
 		parser.OptionWithDownloads(false),
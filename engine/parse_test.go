@@ -32,10 +32,10 @@ func TestParseTF(t *testing.T) {
 
 		t.Run(entry.Name(), func(t *testing.T) {
 			ctx := context.Background()
-			_, modules, _, err := engine.ParseTerraform(ctx, coderism.Input{}, dir)
+			psr, modules, _, err := engine.ParseTerraform(ctx, coderism.Input{}, dir)
 			require.NoError(t, err)
 
-			output, err := coderism.Extract(modules, coderism.Input{})
+			output, err := coderism.Extract(modules, psr.Files(), coderism.Input{})
 			require.NoError(t, err)
 			fmt.Println(output)
 		})
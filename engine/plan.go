@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+
+	tfjson "github.com/hashicorp/terraform-json"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+// ParseTFPlan loads the output of 'terraform show -json' of a plan file.
+func ParseTFPlan(dir fs.FS, filename string) (*tfjson.Plan, error) {
+	input, err := readTFShow(dir, filename)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalTFPlan(input)
+}
+
+type PlanValueKind string
+
+const (
+	PlanValueWorkspaceTag PlanValueKind = "workspace_tag"
+	PlanValueParameter    PlanValueKind = "parameter"
+)
+
+type PlanValueStatus string
+
+const (
+	// PlanValueMatch means the preview has the planned value.
+	PlanValueMatch PlanValueStatus = "match"
+	// PlanValueDifferent means the preview has a different value.
+	PlanValueDifferent PlanValueStatus = "different"
+	// PlanValueUnknown means the value is not known in the preview.
+	PlanValueUnknown PlanValueStatus = "unknown"
+	// PlanValueMissingInPlan and PlanValueMissingInPreview mean only one
+	// side has the tag or parameter.
+	PlanValueMissingInPlan    PlanValueStatus = "missing_in_plan"
+	PlanValueMissingInPreview PlanValueStatus = "missing_in_preview"
+	// PlanValueInvalid means the preview value is not a valid workspace
+	// tag value, like a list.
+	PlanValueInvalid PlanValueStatus = "invalid"
+)
+
+// PlanValue is a workspace tag or parameter value, as previewed and as
+// planned by terraform.
+type PlanValue struct {
+	Kind    PlanValueKind   `json:"kind"`
+	Name    string          `json:"name"`
+	Status  PlanValueStatus `json:"status"`
+	Preview string          `json:"preview"`
+	Planned string          `json:"planned"`
+	// Error is why the preview value is invalid.
+	Error string `json:"error,omitempty"`
+}
+
+type PlanComparison struct {
+	Values []PlanValue `json:"values"`
+}
+
+// Differences are the values where the preview does not agree with the
+// plan. Values that are not known in the preview are not differences.
+func (c PlanComparison) Differences() []PlanValue {
+	var diffs []PlanValue
+	for _, v := range c.Values {
+		switch v.Status {
+		case PlanValueMatch, PlanValueUnknown:
+			continue
+		}
+		diffs = append(diffs, v)
+	}
+	return diffs
+}
+
+// ComparePlan compares the workspace tags and parameter values of the
+// preview with the values of the 'coder_workspace_tags' and
+// 'coder_parameter' data sources in the plan. A plan has the values of
+// data sources that are read during the plan, so they are the values a
+// workspace build gets. A workspace tag that is not valid in the preview is
+// compared as invalid, rather than failing the comparison. A workspace tag
+// with a key that is not known could be any of the planned tags, so the
+// planned tags that are not in the preview are unknown then.
+func ComparePlan(output coderism.Output, plan *tfjson.Plan) PlanComparison {
+	previewTags := make(map[string]*string)
+	invalidTags := make(map[string]string)
+	unknownKeys := false
+	for _, block := range output.WorkspaceTags {
+		for _, tag := range block.Tags {
+			// Like ValidTags, the last block wins.
+			switch {
+			case !tag.KeyIsKnown():
+				unknownKeys = true
+				continue
+			case !tag.IsKnown():
+				previewTags[tag.SafeKeyString()] = nil
+				continue
+			}
+			k, v, diags := tag.EvalToString(block)
+			if diags.HasErrors() {
+				invalidTags[tag.SafeKeyString()] = diags.Error()
+				continue
+			}
+			previewTags[k] = &v
+		}
+	}
+
+	previewParams := make(map[string]*string, len(output.Parameters))
+	for _, param := range output.Parameters {
+		if !param.Value.Value.IsKnown() {
+			previewParams[param.Data.Name] = nil
+			continue
+		}
+		v, err := param.ValueAsString()
+		if err != nil {
+			previewParams[param.Data.Name] = nil
+			continue
+		}
+		previewParams[param.Data.Name] = &v
+	}
+
+	plannedTags, plannedParams := planValues(plan)
+
+	var comparison PlanComparison
+	comparison.Values = append(comparison.Values, comparePlanValues(PlanValueWorkspaceTag, previewTags, invalidTags, unknownKeys, plannedTags)...)
+	comparison.Values = append(comparison.Values, comparePlanValues(PlanValueParameter, previewParams, nil, false, plannedParams)...)
+	return comparison
+}
+
+// planValues finds the workspace tags and parameter values in the planned
+// values, or in the prior state for data sources read before the plan.
+func planValues(plan *tfjson.Plan) (map[string]string, map[string]string) {
+	tags := make(map[string]string)
+	params := make(map[string]string)
+	if plan == nil {
+		return tags, params
+	}
+
+	seen := make(map[string]bool)
+	var walk func(module *tfjson.StateModule)
+	walk = func(module *tfjson.StateModule) {
+		if module == nil {
+			return
+		}
+		for _, resource := range module.Resources {
+			if resource.Mode != tfjson.DataResourceMode || seen[resource.Address] {
+				continue
+			}
+			seen[resource.Address] = true
+
+			switch resource.Type {
+			case "coder_workspace_tags":
+				values, _ := resource.AttributeValues["tags"].(map[string]interface{})
+				for k, v := range values {
+					tags[k] = fmt.Sprint(v)
+				}
+			case "coder_parameter":
+				name, _ := resource.AttributeValues["name"].(string)
+				if v, ok := resource.AttributeValues["value"]; ok && name != "" && v != nil {
+					params[name] = fmt.Sprint(v)
+				}
+			}
+		}
+		for _, child := range module.ChildModules {
+			walk(child)
+		}
+	}
+
+	if plan.PlannedValues != nil {
+		walk(plan.PlannedValues.RootModule)
+	}
+	if plan.PriorState != nil && plan.PriorState.Values != nil {
+		walk(plan.PriorState.Values.RootModule)
+	}
+	return tags, params
+}
+
+// comparePlanValues compares the values by name. A nil preview value is
+// not known, and invalid has the error of the preview values that are not
+// valid. If the preview has names that are not known, the planned values
+// that are not in the preview are not known either.
+func comparePlanValues(kind PlanValueKind, preview map[string]*string, invalid map[string]string, unknownNames bool, planned map[string]string) []PlanValue {
+	names := make([]string, 0, len(preview)+len(invalid)+len(planned))
+	for name := range preview {
+		names = append(names, name)
+	}
+	for name := range invalid {
+		if _, ok := preview[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range planned {
+		_, inPreview := preview[name]
+		_, isInvalid := invalid[name]
+		if !inPreview && !isInvalid {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	values := make([]PlanValue, 0, len(names))
+	for _, name := range names {
+		value := PlanValue{Kind: kind, Name: name}
+		pv, inPreview := preview[name]
+		plannedValue, inPlan := planned[name]
+		value.Planned = plannedValue
+		if pv != nil {
+			value.Preview = *pv
+		}
+
+		errMsg, isInvalid := invalid[name]
+		switch {
+		case isInvalid && !inPreview:
+			value.Status = PlanValueInvalid
+			value.Error = errMsg
+		case !inPreview && unknownNames:
+			value.Status = PlanValueUnknown
+		case !inPreview:
+			value.Status = PlanValueMissingInPreview
+		case pv == nil:
+			// An unknown value is never a difference.
+			value.Status = PlanValueUnknown
+		case !inPlan:
+			value.Status = PlanValueMissingInPlan
+		case *pv != plannedValue:
+			value.Status = PlanValueDifferent
+		default:
+			value.Status = PlanValueMatch
+		}
+		values = append(values, value)
+	}
+	return values
+}
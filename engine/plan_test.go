@@ -0,0 +1,178 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+const planMain = `
+data "coder_parameter" "region" {
+	name    = "region"
+	default = "us"
+}
+
+data "coder_parameter" "size" {
+	name    = "size"
+	default = "small"
+}
+
+resource "docker_image" "ubuntu" {
+	name = "ubuntu:latest"
+}
+
+data "coder_workspace_tags" "tags" {
+	tags = {
+		"region" = data.coder_parameter.region.value
+		"size"   = data.coder_parameter.size.value
+		"image"  = docker_image.ubuntu.repo_digest
+		"static" = "yes"
+		"list"   = ["a", "b"]
+	}
+}
+`
+
+const planJSON = `{
+	"format_version": "1.2",
+	"planned_values": {
+		"root_module": {
+			"resources": [
+				{
+					"address": "data.coder_parameter.region",
+					"mode": "data",
+					"type": "coder_parameter",
+					"name": "region",
+					"values": {"name": "region", "value": "us"}
+				},
+				{
+					"address": "data.coder_parameter.size",
+					"mode": "data",
+					"type": "coder_parameter",
+					"name": "size",
+					"values": {"name": "size", "value": "large"}
+				},
+				{
+					"address": "data.coder_parameter.zone",
+					"mode": "data",
+					"type": "coder_parameter",
+					"name": "zone",
+					"values": {"name": "zone", "value": "a"}
+				}
+			]
+		}
+	},
+	"prior_state": {
+		"format_version": "1.0",
+		"values": {
+			"root_module": {
+				"resources": [
+					{
+						"address": "data.coder_workspace_tags.tags",
+						"mode": "data",
+						"type": "coder_workspace_tags",
+						"name": "tags",
+						"values": {"tags": {"region": "us", "size": "large", "image": "ubuntu@sha256:abc"}}
+					}
+				]
+			}
+		}
+	}
+}`
+
+func TestComparePlan(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(planMain), 0644)
+	require.NoError(t, err)
+	err = afero.WriteFile(memfs, "plan.json", []byte(planJSON), 0644)
+	require.NoError(t, err)
+	dir := afero.NewIOFS(memfs)
+
+	plan, err := engine.ParseTFPlan(dir, "plan.json")
+	require.NoError(t, err)
+
+	psr, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, dir)
+	require.NoError(t, err)
+	output, _ := coderism.Extract(modules, psr.Files(), coderism.Input{})
+
+	comparison := engine.ComparePlan(output, plan)
+	// The error of the invalid tag is the diagnostic of its value.
+	require.Equal(t, "list", comparison.Values[1].Name)
+	require.Contains(t, comparison.Values[1].Error, "main.tf")
+	comparison.Values[1].Error = ""
+
+	require.Equal(t, []engine.PlanValue{
+		{Kind: engine.PlanValueWorkspaceTag, Name: "image", Status: engine.PlanValueUnknown, Planned: "ubuntu@sha256:abc"},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "list", Status: engine.PlanValueInvalid},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "region", Status: engine.PlanValueMatch, Preview: "us", Planned: "us"},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "size", Status: engine.PlanValueDifferent, Preview: "small", Planned: "large"},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "static", Status: engine.PlanValueMissingInPlan, Preview: "yes"},
+		{Kind: engine.PlanValueParameter, Name: "region", Status: engine.PlanValueMatch, Preview: "us", Planned: "us"},
+		{Kind: engine.PlanValueParameter, Name: "size", Status: engine.PlanValueDifferent, Preview: "small", Planned: "large"},
+		{Kind: engine.PlanValueParameter, Name: "zone", Status: engine.PlanValueMissingInPreview, Planned: "a"},
+	}, comparison.Values)
+	require.Len(t, comparison.Differences(), 5)
+}
+
+func TestComparePlan_UnknownKey(t *testing.T) {
+	t.Parallel()
+
+	const main = `
+		resource "docker_image" "ubuntu" {
+			name = "ubuntu:latest"
+		}
+
+		data "coder_workspace_tags" "tags" {
+			tags = {
+				"region"                        = "us"
+				"image"                         = docker_image.ubuntu.repo_digest
+				(docker_image.ubuntu.image_id) = "cached"
+			}
+		}`
+	const plan = `{
+		"format_version": "1.2",
+		"planned_values": {
+			"root_module": {
+				"resources": [
+					{
+						"address": "data.coder_workspace_tags.tags",
+						"mode": "data",
+						"type": "coder_workspace_tags",
+						"name": "tags",
+						"values": {"tags": {"region": "us", "sha256:abc": "cached"}}
+					}
+				]
+			}
+		}
+	}`
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+	require.NoError(t, err)
+	err = afero.WriteFile(memfs, "plan.json", []byte(plan), 0644)
+	require.NoError(t, err)
+	dir := afero.NewIOFS(memfs)
+
+	tfplan, err := engine.ParseTFPlan(dir, "plan.json")
+	require.NoError(t, err)
+
+	psr, modules, _, err := engine.ParseTerraform(context.Background(), coderism.Input{}, dir)
+	require.NoError(t, err)
+	output, _ := coderism.Extract(modules, psr.Files(), coderism.Input{})
+
+	// The tag with the unknown key could be the planned 'sha256:abc', and
+	// the unknown 'image' value is not in the plan, neither is a difference.
+	comparison := engine.ComparePlan(output, tfplan)
+	require.Equal(t, []engine.PlanValue{
+		{Kind: engine.PlanValueWorkspaceTag, Name: "image", Status: engine.PlanValueUnknown},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "region", Status: engine.PlanValueMatch, Preview: "us", Planned: "us"},
+		{Kind: engine.PlanValueWorkspaceTag, Name: "sha256:abc", Status: engine.PlanValueUnknown, Planned: "cached"},
+	}, comparison.Values)
+	require.Empty(t, comparison.Differences())
+}
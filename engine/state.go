@@ -1,56 +1,83 @@
 package engine
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 
-	"github.com/aquasecurity/trivy/pkg/iac/scanners/terraform/parser"
 	tfjson "github.com/hashicorp/terraform-json"
-	"github.com/spf13/afero"
 )
 
-var _ = tfjson.ActionRead
+// ParseTFShow loads the output of 'terraform show -json', of either a state
+// or a plan file. Set it as coderism.Input.State to use the values of the
+// existing resources in the preview.
+func ParseTFShow(dir fs.FS, filename string) (*tfjson.State, error) {
+	input, err := readTFShow(dir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTFState(input)
+}
 
-func ParseTFShow(dir fs.FS, filename string) error {
+// readTFShow reads the output of 'terraform show -json'.
+func readTFShow(dir fs.FS, filename string) (json.RawMessage, error) {
 	jsonData, err := dir.Open(filename)
 	if err != nil {
-		return fmt.Errorf("open 'show' json: %w", err)
+		return nil, fmt.Errorf("open 'show' json: %w", err)
 	}
 	defer jsonData.Close()
 
 	input, err := io.ReadAll(jsonData)
 	if err != nil {
-		return fmt.Errorf("read 'show' json: %w", err)
+		return nil, fmt.Errorf("read 'show' json: %w", err)
 	}
+	return input, nil
+}
 
-	var state tfjson.State
-	err = json.Unmarshal(input, &state)
+// ParseTFState parses the JSON of 'terraform show -json'. For a plan, the
+// state before the plan is used, or the planned values if there is none.
+func ParseTFState(input json.RawMessage) (*tfjson.State, error) {
+	// Only a plan has these fields.
+	var kind struct {
+		PlannedValues json.RawMessage `json:"planned_values"`
+		PriorState    json.RawMessage `json:"prior_state"`
+	}
+	err := json.Unmarshal(input, &kind)
 	if err != nil {
-		return fmt.Errorf("unmarshal: %w", err)
+		return nil, fmt.Errorf("unmarshal: %w", err)
 	}
 
-	return nil
-}
-
-func ParseTFState(ctx context.Context, input json.RawMessage) error {
-	mem := afero.NewMemMapFs()
-	err := afero.WriteFile(mem, "main.tf", input, 0644)
-	if err != nil {
-		return fmt.Errorf("mem fs: %w", err)
+	if kind.PlannedValues == nil && kind.PriorState == nil {
+		var state tfjson.State
+		err = json.Unmarshal(input, &state)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal state: %w", err)
+		}
+		return &state, nil
 	}
 
-	p := parser.New(afero.NewIOFS(mem), "")
-	err = p.ParseFS(ctx, ".")
+	plan, err := unmarshalTFPlan(input)
 	if err != nil {
-		return fmt.Errorf("parse terraform: %w", err)
+		return nil, err
 	}
-	modules, _, err := p.EvaluateAll(ctx)
+	if plan.PriorState != nil && plan.PriorState.Values != nil {
+		return plan.PriorState, nil
+	}
+	return &tfjson.State{
+		FormatVersion:    plan.FormatVersion,
+		TerraformVersion: plan.TerraformVersion,
+		Values:           plan.PlannedValues,
+	}, nil
+}
+
+// unmarshalTFPlan parses the JSON of 'terraform show -json' of a plan file.
+func unmarshalTFPlan(input json.RawMessage) (*tfjson.Plan, error) {
+	var plan tfjson.Plan
+	err := json.Unmarshal(input, &plan)
 	if err != nil {
-		return fmt.Errorf("evaluate all: %w", err)
+		return nil, fmt.Errorf("unmarshal plan: %w", err)
 	}
-	_ = modules
-	return nil
+	return &plan, nil
 }
@@ -0,0 +1,152 @@
+package engine_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+const stateMain = `
+resource "docker_volume" "home" {
+	count = 2
+	name  = "home-${count.index}"
+}
+
+resource "docker_container" "workspace" {
+	image = "ubuntu"
+}
+
+data "coder_workspace_tags" "tags" {
+	tags = {
+		"volume"    = docker_volume.home[1].mountpoint
+		"name"      = docker_volume.home[1].name
+		"container" = docker_container.workspace.id
+	}
+}
+`
+
+const stateResources = `{
+	"root_module": {
+		"resources": [
+			{
+				"address": "docker_volume.home[0]",
+				"mode": "managed",
+				"type": "docker_volume",
+				"name": "home",
+				"index": 0,
+				"values": {"name": "home-0", "mountpoint": "/var/lib/docker/volumes/home-0"}
+			},
+			{
+				"address": "docker_volume.home[1]",
+				"mode": "managed",
+				"type": "docker_volume",
+				"name": "home",
+				"index": 1,
+				"values": {"name": "stale", "mountpoint": "/var/lib/docker/volumes/home-1"}
+			},
+			{
+				"address": "docker_container.workspace",
+				"mode": "managed",
+				"type": "docker_container",
+				"name": "workspace",
+				"values": {"id": "c0ffee", "image": "ubuntu"}
+			}
+		]
+	}
+}`
+
+func TestParseTFShow(t *testing.T) {
+	t.Parallel()
+
+	expectTags := map[string]string{
+		"volume":    "/var/lib/docker/volumes/home-1",
+		"name":      "home-1",
+		"container": "c0ffee",
+	}
+
+	for _, tc := range []struct {
+		name string
+		show string
+	}{
+		{
+			name: "state",
+			show: `{"format_version": "1.0", "values": ` + stateResources + `}`,
+		},
+		{
+			name: "plan prior state",
+			show: `{
+				"format_version": "1.2",
+				"planned_values": {"root_module": {}},
+				"prior_state": {"format_version": "1.0", "values": ` + stateResources + `}
+			}`,
+		},
+		{
+			name: "plan planned values",
+			show: `{"format_version": "1.2", "planned_values": ` + stateResources + `}`,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(stateMain), 0644)
+			require.NoError(t, err)
+			err = afero.WriteFile(memfs, "show.json", []byte(tc.show), 0644)
+			require.NoError(t, err)
+			dir := afero.NewIOFS(memfs)
+
+			state, err := engine.ParseTFShow(dir, "show.json")
+			require.NoError(t, err)
+			input := coderism.Input{State: state}
+
+			psr, modules, _, err := engine.ParseTerraform(context.Background(), input, dir)
+			require.NoError(t, err)
+
+			output, diags := coderism.Extract(modules, psr.Files(), input)
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			tags, err := output.WorkspaceTags.ValidTags()
+			require.NoError(t, err)
+			// The name is set in the template, so the stale state value
+			// is not used.
+			require.Equal(t, expectTags, tags)
+		})
+	}
+}
+
+func TestParseTFShow_InvalidAttribute(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(stateMain), 0644)
+	require.NoError(t, err)
+	err = afero.WriteFile(memfs, "show.json", []byte(`{"format_version": "1.0", "values": `+stateResources+`}`), 0644)
+	require.NoError(t, err)
+	dir := afero.NewIOFS(memfs)
+
+	state, err := engine.ParseTFShow(dir, "show.json")
+	require.NoError(t, err)
+	// An attribute that cannot be encoded as JSON does not hide the other
+	// attributes of the resource.
+	container := state.Values.RootModule.Resources[2]
+	require.Equal(t, "docker_container.workspace", container.Address)
+	container.AttributeValues["cpu_shares"] = math.Inf(1)
+	input := coderism.Input{State: state}
+
+	psr, modules, _, err := engine.ParseTerraform(context.Background(), input, dir)
+	require.NoError(t, err)
+
+	output, diags := coderism.Extract(modules, psr.Files(), input)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	tags, err := output.WorkspaceTags.ValidTags()
+	require.NoError(t, err)
+	require.Equal(t, "c0ffee", tags["container"])
+}
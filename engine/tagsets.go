@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+// DefaultMaxTagCombinations is the number of parameter combinations that
+// are evaluated when TagSetOptions.MaxCombinations is not set. Every
+// combination parses the whole template again.
+const DefaultMaxTagCombinations = 256
+
+type TagSetOptions struct {
+	// MaxCombinations caps the number of combinations that are evaluated.
+	// If there are more, a random sample of this size is evaluated.
+	MaxCombinations int
+	// Seed is the seed of the random sample, so a sample can be repeated.
+	Seed int64
+}
+
+// TagSet is a distinct set of workspace tags, and the parameter values
+// that produce it.
+type TagSet struct {
+	Tags         map[string]string   `json:"tags"`
+	Unknowns     []string            `json:"unknowns"`
+	Combinations []map[string]string `json:"combinations"`
+}
+
+type TagSetReport struct {
+	// Parameters are the names of the parameters with options that were
+	// enumerated, as named in the parameter values of the input.
+	Parameters []string `json:"parameters"`
+	// Total is the number of combinations of the option values. It is
+	// math.MaxInt if there are too many to count.
+	Total     int      `json:"total"`
+	Evaluated int      `json:"evaluated"`
+	Sampled   bool     `json:"sampled"`
+	TagSets   []TagSet `json:"tag_sets"`
+}
+
+// WorkspaceTagSets evaluates the workspace tags for every combination of
+// the option values of the parameters, and groups the combinations by the
+// tags they produce. Parameters with a value in the input are not
+// enumerated. The options are taken from the template evaluated with the
+// input, so options that depend on other parameters are approximated.
+func WorkspaceTagSets(ctx context.Context, input coderism.Input, dir fs.FS, opts TagSetOptions) (TagSetReport, error) {
+	if opts.MaxCombinations <= 0 {
+		opts.MaxCombinations = DefaultMaxTagCombinations
+	}
+
+	psr, modules, _, err := ParseTerraform(ctx, input, dir)
+	if err != nil {
+		return TagSetReport{}, err
+	}
+	output, _ := coderism.Extract(modules, psr.Files(), input)
+
+	var names []string
+	var options [][]string
+	seen := make(map[string]bool)
+	for _, param := range output.Parameters {
+		name := param.InputName()
+		if len(param.Data.Options) == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := input.RichParameterValue(name); ok {
+			continue
+		}
+
+		values := make([]string, 0, len(param.Data.Options))
+		for _, opt := range param.Data.Options {
+			values = append(values, optionInputValue(param.Data.Type, opt.Value))
+		}
+		names = append(names, name)
+		options = append(options, values)
+	}
+
+	report := TagSetReport{
+		Parameters: names,
+		Total:      combinationCount(options),
+		TagSets:    []TagSet{},
+	}
+
+	var combinations [][]int
+	if report.Total <= opts.MaxCombinations {
+		combinations = allCombinations(options, report.Total)
+	} else {
+		report.Sampled = true
+		combinations = sampleCombinations(options, opts.MaxCombinations, rand.New(rand.NewSource(opts.Seed)))
+	}
+
+	bySet := make(map[string]int)
+	for _, combination := range combinations {
+		values := make(map[string]string, len(names))
+		for i, name := range names {
+			values[name] = options[i][combination[i]]
+		}
+
+		tags, unknowns, err := combinationTags(ctx, input, dir, values)
+		if err != nil {
+			return report, fmt.Errorf("parameters %s: %w", combinationString(names, values), err)
+		}
+		report.Evaluated++
+
+		key := tagSetKey(tags, unknowns)
+		idx, ok := bySet[key]
+		if !ok {
+			idx = len(report.TagSets)
+			bySet[key] = idx
+			report.TagSets = append(report.TagSets, TagSet{
+				Tags:     tags,
+				Unknowns: unknowns,
+			})
+		}
+		report.TagSets[idx].Combinations = append(report.TagSets[idx].Combinations, values)
+	}
+	return report, nil
+}
+
+// optionInputValue is the input value that selects the option. The value of
+// a multi-select parameter, of type list(string), is a JSON array, so the
+// option is selected on its own.
+func optionInputValue(paramType, value string) string {
+	if paramType != "list(string)" {
+		return value
+	}
+	data, err := json.Marshal([]string{value})
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+func combinationTags(ctx context.Context, input coderism.Input, dir fs.FS, values map[string]string) (map[string]string, []string, error) {
+	paramValues := make([]*proto.RichParameterValue, 0, len(input.ParameterValues)+len(values))
+	paramValues = append(paramValues, input.ParameterValues...)
+	for name, value := range values {
+		paramValues = append(paramValues, &proto.RichParameterValue{Name: name, Value: value})
+	}
+	input.ParameterValues = paramValues
+
+	_, modules, _, err := ParseTerraform(ctx, input, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks, _ := coderism.WorkspaceTags(modules)
+	tags, err := blocks.ValidTags()
+	if err != nil {
+		return nil, nil, err
+	}
+	unknowns := blocks.Unknowns()
+	sort.Strings(unknowns)
+	return tags, unknowns, nil
+}
+
+// combinationCount is the size of the Cartesian product of the options,
+// saturated at math.MaxInt.
+func combinationCount(options [][]string) int {
+	total := 1
+	for _, values := range options {
+		if len(values) == 0 {
+			return 0
+		}
+		if total > math.MaxInt/len(values) {
+			return math.MaxInt
+		}
+		total *= len(values)
+	}
+	return total
+}
+
+// allCombinations returns the option indexes of every combination. The last
+// parameter changes the fastest.
+func allCombinations(options [][]string, total int) [][]int {
+	combinations := make([][]int, 0, total)
+	for n := 0; n < total; n++ {
+		combination := make([]int, len(options))
+		rest := n
+		for i := len(options) - 1; i >= 0; i-- {
+			combination[i] = rest % len(options[i])
+			rest /= len(options[i])
+		}
+		combinations = append(combinations, combination)
+	}
+	return combinations
+}
+
+// sampleCombinations picks up to max distinct combinations at random.
+func sampleCombinations(options [][]string, max int, rnd *rand.Rand) [][]int {
+	combinations := make([][]int, 0, max)
+	seen := make(map[string]bool)
+	// Give up eventually, duplicates are likely if max is close to the
+	// total.
+	for attempts := 0; len(combinations) < max && attempts < max*10; attempts++ {
+		combination := make([]int, len(options))
+		key := make([]string, len(options))
+		for i, values := range options {
+			combination[i] = rnd.Intn(len(values))
+			key[i] = fmt.Sprint(combination[i])
+		}
+		k := strings.Join(key, ",")
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		combinations = append(combinations, combination)
+	}
+	return combinations
+}
+
+func tagSetKey(tags map[string]string, unknowns []string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%q=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",") + "|" + strings.Join(unknowns, ",")
+}
+
+func combinationString(names []string, values map[string]string) string {
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, values[name]))
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,193 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+)
+
+const tagSetsMain = `
+data "coder_parameter" "region" {
+	name    = "region"
+	default = "us"
+	option {
+		name  = "US"
+		value = "us"
+	}
+	option {
+		name  = "EU"
+		value = "eu"
+	}
+}
+
+data "coder_parameter" "size" {
+	name    = "size"
+	default = "small"
+	option {
+		name  = "Small"
+		value = "small"
+	}
+	option {
+		name  = "Medium"
+		value = "medium"
+	}
+	option {
+		name  = "Large"
+		value = "large"
+	}
+}
+
+data "coder_workspace_tags" "tags" {
+	tags = {
+		"zone" = data.coder_parameter.region.value
+		"gpu"  = data.coder_parameter.size.value == "large" ? "true" : "false"
+	}
+}
+`
+
+func TestWorkspaceTagSets(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(tagSetsMain), 0644)
+	require.NoError(t, err)
+	dir := afero.NewIOFS(memfs)
+
+	t.Run("all", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := engine.WorkspaceTagSets(context.Background(), coderism.Input{}, dir, engine.TagSetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"region", "size"}, report.Parameters)
+		require.Equal(t, 6, report.Total)
+		require.Equal(t, 6, report.Evaluated)
+		require.False(t, report.Sampled)
+
+		got := make(map[string]int)
+		for _, set := range report.TagSets {
+			got[set.Tags["zone"]+"/"+set.Tags["gpu"]] = len(set.Combinations)
+		}
+		require.Equal(t, map[string]int{
+			"us/false": 2,
+			"us/true":  1,
+			"eu/false": 2,
+			"eu/true":  1,
+		}, got)
+	})
+
+	t.Run("fixed input", func(t *testing.T) {
+		t.Parallel()
+
+		input := coderism.Input{
+			ParameterValues: []*proto.RichParameterValue{{Name: "region", Value: "eu"}},
+		}
+		report, err := engine.WorkspaceTagSets(context.Background(), input, dir, engine.TagSetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"size"}, report.Parameters)
+		require.Len(t, report.TagSets, 2)
+		for _, set := range report.TagSets {
+			require.Equal(t, "eu", set.Tags["zone"])
+		}
+	})
+
+	t.Run("label is not the name", func(t *testing.T) {
+		t.Parallel()
+
+		const main = `
+			data "coder_parameter" "size" {
+				name    = "Instance size"
+				default = "small"
+				option {
+					name  = "Small"
+					value = "small"
+				}
+				option {
+					name  = "Large"
+					value = "large"
+				}
+			}
+
+			data "coder_workspace_tags" "tags" {
+				tags = {
+					"size" = data.coder_parameter.size.value
+				}
+			}`
+		memfs := afero.NewMemMapFs()
+		err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+		require.NoError(t, err)
+
+		report, err := engine.WorkspaceTagSets(context.Background(), coderism.Input{}, afero.NewIOFS(memfs), engine.TagSetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"size"}, report.Parameters)
+
+		got := make(map[string][]map[string]string)
+		for _, set := range report.TagSets {
+			got[set.Tags["size"]] = set.Combinations
+		}
+		require.Equal(t, map[string][]map[string]string{
+			"small": {{"size": "small"}},
+			"large": {{"size": "large"}},
+		}, got)
+	})
+
+	t.Run("multi-select", func(t *testing.T) {
+		t.Parallel()
+
+		const main = `
+			data "coder_parameter" "regions" {
+				name    = "regions"
+				type    = "list(string)"
+				default = jsonencode(["us"])
+				option {
+					name  = "US"
+					value = "us"
+				}
+				option {
+					name  = "EU"
+					value = "eu"
+				}
+			}
+
+			data "coder_workspace_tags" "tags" {
+				tags = {
+					"regions" = join(",", data.coder_parameter.regions.value)
+				}
+			}`
+		memfs := afero.NewMemMapFs()
+		err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+		require.NoError(t, err)
+
+		report, err := engine.WorkspaceTagSets(context.Background(), coderism.Input{}, afero.NewIOFS(memfs), engine.TagSetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"regions"}, report.Parameters)
+
+		got := make(map[string][]map[string]string)
+		for _, set := range report.TagSets {
+			require.Empty(t, set.Unknowns)
+			got[set.Tags["regions"]] = set.Combinations
+		}
+		require.Equal(t, map[string][]map[string]string{
+			"us": {{"regions": `["us"]`}},
+			"eu": {{"regions": `["eu"]`}},
+		}, got)
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := engine.WorkspaceTagSets(context.Background(), coderism.Input{}, dir, engine.TagSetOptions{
+			MaxCombinations: 3,
+			Seed:            1,
+		})
+		require.NoError(t, err)
+		require.True(t, report.Sampled)
+		require.Equal(t, 6, report.Total)
+		require.Equal(t, 3, report.Evaluated)
+	})
+}
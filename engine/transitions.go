@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+// TransitionState is what a workspace build creates for a transition.
+type TransitionState struct {
+	Transition string `json:"transition"`
+	// Resources are the addresses of the resource and module instances.
+	Resources []string          `json:"resources"`
+	Agents    []string          `json:"agents"`
+	Tags      map[string]string `json:"tags"`
+	Unknowns  []string          `json:"unknowns"`
+}
+
+type TransitionChangeKind string
+
+const (
+	TransitionChangeResource TransitionChangeKind = "resource"
+	TransitionChangeAgent    TransitionChangeKind = "agent"
+	TransitionChangeTag      TransitionChangeKind = "tag"
+)
+
+// TransitionChange is a resource, agent or tag, and its value in each
+// transition. Resources and agents are "present" or "absent". Tags are the
+// value, "??" if not known, or "" if not set.
+type TransitionChange struct {
+	Kind    TransitionChangeKind `json:"kind"`
+	Address string               `json:"address"`
+	Values  map[string]string    `json:"values"`
+	// Changed is true if the value is not the same in every transition.
+	Changed bool `json:"changed"`
+}
+
+type TransitionReport struct {
+	Transitions []string           `json:"transitions"`
+	States      []TransitionState  `json:"states"`
+	Changes     []TransitionChange `json:"changes"`
+}
+
+// CompareTransitions evaluates the template as it is built for each
+// workspace transition, and reports what exists in which transition. The
+// 'start_count' and 'transition' of the workspace data source are set for
+// each transition, the rest of the input is used as is.
+func CompareTransitions(ctx context.Context, input coderism.Input, dir fs.FS) (TransitionReport, error) {
+	report := TransitionReport{
+		Transitions: coderism.Transitions,
+		States:      make([]TransitionState, 0, len(coderism.Transitions)),
+	}
+
+	for _, transition := range coderism.Transitions {
+		state, err := transitionState(ctx, input, dir, transition)
+		if err != nil {
+			return report, fmt.Errorf("transition %q: %w", transition, err)
+		}
+		report.States = append(report.States, state)
+	}
+
+	report.Changes = append(report.Changes, presenceChanges(TransitionChangeResource, report.States, func(s TransitionState) []string { return s.Resources })...)
+	report.Changes = append(report.Changes, presenceChanges(TransitionChangeAgent, report.States, func(s TransitionState) []string { return s.Agents })...)
+	report.Changes = append(report.Changes, tagChanges(report.States)...)
+	return report, nil
+}
+
+func transitionState(ctx context.Context, input coderism.Input, dir fs.FS, transition string) (TransitionState, error) {
+	sources := input.DataSources.WithDefaults()
+	workspace := sources.Workspace.WithTransition(transition)
+	sources.Workspace = &workspace
+	input.DataSources = sources
+
+	_, modules, _, err := ParseTerraform(ctx, input, dir)
+	if err != nil {
+		return TransitionState{}, err
+	}
+
+	state := TransitionState{
+		Transition: transition,
+		Resources:  []string{},
+		Agents:     []string{},
+	}
+	for _, module := range modules {
+		for _, block := range module.GetBlocks() {
+			switch block.Type() {
+			case "resource", "module":
+			default:
+				continue
+			}
+			address := block.FullName()
+			state.Resources = append(state.Resources, address)
+			if block.Type() == "resource" && block.TypeLabel() == "coder_agent" {
+				state.Agents = append(state.Agents, address)
+			}
+		}
+	}
+	sort.Strings(state.Resources)
+	sort.Strings(state.Agents)
+
+	blocks, _ := coderism.WorkspaceTags(modules)
+	state.Tags, err = blocks.ValidTags()
+	if err != nil {
+		return TransitionState{}, err
+	}
+	state.Unknowns = blocks.Unknowns()
+	sort.Strings(state.Unknowns)
+	return state, nil
+}
+
+func presenceChanges(kind TransitionChangeKind, states []TransitionState, addresses func(TransitionState) []string) []TransitionChange {
+	present := make(map[string]map[string]bool)
+	var all []string
+	for _, state := range states {
+		for _, address := range addresses(state) {
+			if present[address] == nil {
+				present[address] = make(map[string]bool)
+				all = append(all, address)
+			}
+			present[address][state.Transition] = true
+		}
+	}
+	sort.Strings(all)
+
+	changes := make([]TransitionChange, 0, len(all))
+	for _, address := range all {
+		change := TransitionChange{
+			Kind:    kind,
+			Address: address,
+			Values:  make(map[string]string, len(states)),
+			Changed: len(present[address]) != len(states),
+		}
+		for _, state := range states {
+			change.Values[state.Transition] = "absent"
+			if present[address][state.Transition] {
+				change.Values[state.Transition] = "present"
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func tagChanges(states []TransitionState) []TransitionChange {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, state := range states {
+		for k := range state.Tags {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range state.Unknowns {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]TransitionChange, 0, len(keys))
+	for _, k := range keys {
+		change := TransitionChange{
+			Kind:    TransitionChangeTag,
+			Address: k,
+			Values:  make(map[string]string, len(states)),
+		}
+		for _, state := range states {
+			v := state.Tags[k]
+			for _, unknown := range state.Unknowns {
+				if unknown == k {
+					v = "??"
+				}
+			}
+			change.Values[state.Transition] = v
+			if v != change.Values[states[0].Transition] {
+				change.Changed = true
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,62 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/terraform-eval/engine"
+	"github.com/coder/terraform-eval/engine/coderism"
+)
+
+const transitionsMain = `
+data "coder_workspace" "me" {}
+
+resource "coder_agent" "main" {
+	count = data.coder_workspace.me.start_count
+	os    = "linux"
+	arch  = "amd64"
+}
+
+resource "docker_volume" "home" {
+	name = "home"
+}
+
+resource "docker_container" "workspace" {
+	count = data.coder_workspace.me.start_count
+	image = "ubuntu"
+}
+
+data "coder_workspace_tags" "tags" {
+	tags = {
+		"transition" = data.coder_workspace.me.transition
+		"pool"       = "default"
+	}
+}
+`
+
+func TestCompareTransitions(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	err := afero.WriteFile(memfs, "main.tf", []byte(transitionsMain), 0644)
+	require.NoError(t, err)
+
+	report, err := engine.CompareTransitions(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+	require.NoError(t, err)
+	require.Equal(t, coderism.Transitions, report.Transitions)
+	require.Len(t, report.States, 3)
+
+	start := map[string]string{"start": "present", "stop": "absent", "delete": "absent"}
+	always := map[string]string{"start": "present", "stop": "present", "delete": "present"}
+	require.Equal(t, []engine.TransitionChange{
+		{Kind: engine.TransitionChangeResource, Address: "coder_agent.main[0]", Values: start, Changed: true},
+		{Kind: engine.TransitionChangeResource, Address: "docker_container.workspace[0]", Values: start, Changed: true},
+		{Kind: engine.TransitionChangeResource, Address: "docker_volume.home", Values: always},
+		{Kind: engine.TransitionChangeAgent, Address: "coder_agent.main[0]", Values: start, Changed: true},
+		{Kind: engine.TransitionChangeTag, Address: "pool", Values: map[string]string{"start": "default", "stop": "default", "delete": "default"}},
+		{Kind: engine.TransitionChangeTag, Address: "transition", Values: map[string]string{"start": "start", "stop": "stop", "delete": "delete"}, Changed: true},
+	}, report.Changes)
+}
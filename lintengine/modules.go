@@ -0,0 +1,64 @@
+package lintengine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint/terraform"
+	"github.com/terraform-linters/tflint/terraform/addrs"
+
+	"github.com/coder/terraform-eval/engine"
+)
+
+// moduleWalker loads the child modules from the template directory, without
+// downloading anything. Local modules are loaded relative to the calling
+// module, and remote modules from where 'terraform init' put them.
+func moduleWalker(tp *terraform.Parser, manifest engine.ModuleManifest) terraform.ModuleWalkerFunc {
+	return func(req *terraform.ModuleRequest) (*terraform.Module, *version.Version, hcl.Diagnostics) {
+		switch source := req.SourceAddr.(type) {
+		case nil:
+			// A module without a source is reported by the parser.
+			return nil, nil, nil
+
+		case addrs.ModuleSourceLocal:
+			dir := path.Join(req.Parent.Module.SourceDir, source.String())
+			if !tp.Exists(dir) {
+				return nil, nil, hcl.Diagnostics{{
+					Severity: hcl.DiagError,
+					Summary:  "Module not found",
+					Detail:   fmt.Sprintf("The directory %q of module %q does not exist.", dir, req.Name),
+					Subject:  &req.CallRange,
+				}}
+			}
+			mod, diags := tp.LoadConfigDir(".", dir)
+			return mod, nil, diags
+
+		default:
+			key := strings.Join(req.Path, ".")
+			record, ok := manifest.Record(key)
+			if !ok || !tp.Exists(path.Clean(record.Dir)) {
+				// The outputs of the module are unknown, the rest of the
+				// template can still be previewed.
+				return nil, nil, hcl.Diagnostics{{
+					Severity: hcl.DiagWarning,
+					Summary:  "Module not available offline",
+					Detail: fmt.Sprintf("The module %q from %q has not been downloaded, so its outputs are unknown. "+
+						"Run 'terraform init' to download it.", req.Name, source.String()),
+					Subject: &req.CallRange,
+				}}
+			}
+
+			var v *version.Version
+			if record.Version != "" {
+				// A version that cannot be parsed only matters for
+				// version constraints, which are not checked here.
+				v, _ = version.NewVersion(record.Version)
+			}
+			mod, diags := tp.LoadConfigDir(".", path.Clean(record.Dir))
+			return mod, v, diags
+		}
+	}
+}
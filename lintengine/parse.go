@@ -3,10 +3,9 @@ package lintengine
 import (
 	"context"
 	"fmt"
-	"io"
 	"io/fs"
+	"sort"
 
-	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/spf13/afero"
@@ -14,7 +13,9 @@ import (
 	"github.com/terraform-linters/tflint/terraform"
 	"github.com/zclconf/go-cty/cty"
 
+	"github.com/coder/terraform-eval/engine"
 	"github.com/coder/terraform-eval/engine/coderism"
+	coderhclext "github.com/coder/terraform-eval/engine/hclext"
 )
 
 func ParseTerraform(ctx context.Context, input coderism.Input, dir fs.FS) (*terraform.Evaluator, *hcl.BodyContent, hcl.Diagnostics) {
@@ -27,21 +28,57 @@ func ParseTerraform(ctx context.Context, input coderism.Input, dir fs.FS) (*terr
 		return nil, nil, diags
 	}
 
-	config, diags := terraform.BuildConfig(mod, terraform.ModuleWalkerFunc(
-		func(req *terraform.ModuleRequest) (*terraform.Module, *version.Version, hcl.Diagnostics) {
+	manifest, err := engine.ReadModuleManifest(dir)
+	if err != nil {
+		return nil, nil, diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid module manifest",
+			Detail:   err.Error(),
+		})
+	}
 
-			return nil, nil, nil
-		}),
-	)
+	config, cdiags := terraform.BuildConfig(mod, moduleWalker(tp, manifest))
+	diags = diags.Extend(cdiags)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
 
+	params := coderParameters(config.Module)
 	extInputs := make(map[string]*terraform.InputValue)
 	for _, v := range input.ParameterValues {
+		ty := cty.String
+		block, ok := params[v.Name]
+		if ok {
+			var tyDiags hcl.Diagnostics
+			ty, tyDiags = coderParameterType(block)
+			diags = diags.Extend(tyDiags)
+		}
+
+		val, err := coderhclext.ParseValue(ty, v.Value)
+		if err != nil {
+			diag := &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid parameter value",
+				Detail: fmt.Sprintf("The value %q for parameter %q cannot be converted to type %s: %s",
+					v.Value, v.Name, ty.FriendlyName(), err.Error()),
+			}
+			if ok {
+				diag.Subject = &block.DefRange
+			}
+			diags = diags.Append(diag)
+			continue
+		}
+
 		extInputs[v.Name] = &terraform.InputValue{
-			Value: cty.StringVal(v.Value),
+			Value: val,
 		}
 	}
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
 
-	variableValues, diags := terraform.VariableValues(config, extInputs)
+	variableValues, vdiags := terraform.VariableValues(config, extInputs)
+	diags = diags.Extend(vdiags)
 	if diags.HasErrors() {
 		return nil, nil, diags
 	}
@@ -53,25 +90,19 @@ func ParseTerraform(ctx context.Context, input coderism.Input, dir fs.FS) (*terr
 		VariableValues: variableValues,
 	}
 
-	// hcl parsed
-	hp, diags := ParseHCL(adfs)
-	if diags.HasErrors() {
-		return nil, nil, diags
+	// The content is of the root module only, child modules are in the
+	// config. The files are keyed by their path, so files with the same
+	// name in different directories do not replace each other.
+	names := make([]string, 0, len(config.Module.Files))
+	for name := range config.Module.Files {
+		names = append(names, name)
 	}
-	var _ = hp
+	sort.Strings(names)
 
-	bodies := make([]hcl.Body, 0)
-	ehp := hclparse.NewParser()
-	for k, v := range hp.Files() {
-		expanded, fdiags := evaluator.ExpandBlock(v.Body, &hclext.BodySchema{})
+	bodies := make([]hcl.Body, 0, len(names))
+	for _, name := range names {
+		expanded, fdiags := evaluator.ExpandBlock(config.Module.Files[name].Body, &hclext.BodySchema{})
 		diags = diags.Extend(fdiags)
-
-		ehp.AddFile(k, &hcl.File{
-			Body:  expanded,
-			Bytes: v.Bytes,
-			Nav:   v.Nav,
-		})
-
 		bodies = append(bodies, expanded)
 	}
 
@@ -82,37 +113,67 @@ func ParseTerraform(ctx context.Context, input coderism.Input, dir fs.FS) (*terr
 	return evaluator, cc, diags
 }
 
+// ParseHCL parses the files of the root module in the directory, keyed by
+// their path. Child modules are not parsed, the module walker loads them.
 func ParseHCL(adfs afero.Fs) (*hclparse.Parser, hcl.Diagnostics) {
-	diags := make(hcl.Diagnostics, 0)
-	hp := hclparse.NewParser()
-	_ = afero.Walk(adfs, ".", func(path string, info fs.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
+	files, diags := terraform.NewParser(adfs).LoadConfigDirFiles(".", ".")
+	if diags.HasErrors() {
+		return nil, diags
+	}
 
-		f, err := adfs.Open(path)
-		if err != nil {
-			return fmt.Errorf("open %q: %w", path, err)
-		}
-		defer f.Close()
+	hp := hclparse.NewParser()
+	for name, file := range files {
+		hp.AddFile(name, file)
+	}
+	return hp, diags
+}
 
-		data, err := io.ReadAll(f)
-		if err != nil {
-			return fmt.Errorf("read %q: %w", path, err)
-		}
+var dataSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "data", LabelNames: []string{"type", "name"}},
+	},
+}
 
-		_, fdiags := hp.ParseHCL(data, info.Name())
-		diags = diags.Extend(fdiags)
+var parameterTypeSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+	},
+}
 
-		// Stop on first hcl error
-		if fdiags.HasErrors() {
-			return fmt.Errorf("parse %q: %w", path, fdiags)
+// coderParameters returns the coder_parameter blocks of the module by their
+// name label, which is how the engine looks up parameter values.
+func coderParameters(mod *terraform.Module) map[string]*hcl.Block {
+	params := make(map[string]*hcl.Block)
+	for _, file := range mod.Files {
+		content, _, _ := file.Body.PartialContent(dataSchema)
+		for _, block := range content.Blocks {
+			if block.Labels[0] == "coder_parameter" {
+				params[block.Labels[1]] = block
+			}
 		}
+	}
+	return params
+}
 
-		return nil
-	})
-	if diags.HasErrors() {
-		return nil, diags
+// coderParameterType decodes the 'type' attribute of a coder_parameter.
+// Parameters without a type, or with a type that cannot be decoded, are
+// strings.
+func coderParameterType(block *hcl.Block) (cty.Type, hcl.Diagnostics) {
+	content, _, _ := block.Body.PartialContent(parameterTypeSchema)
+	attr, ok := content.Attributes["type"]
+	if !ok {
+		return cty.String, nil
+	}
+
+	ty, _, err := coderhclext.DecodeVarType(attr.Expr)
+	if err != nil {
+		return cty.String, hcl.Diagnostics{{
+			Severity: hcl.DiagWarning,
+			Summary:  fmt.Sprintf("Decoding parameter type for %q", block.Labels[1]),
+			Detail:   err.Error(),
+			Subject:  &attr.Range,
+			Context:  &block.DefRange,
+		}}
 	}
-	return hp, nil
+	return ty, nil
 }
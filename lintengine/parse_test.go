@@ -0,0 +1,245 @@
+package lintengine_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint/terraform"
+
+	"github.com/coder/terraform-eval/engine/coderism"
+	"github.com/coder/terraform-eval/engine/coderism/proto"
+	"github.com/coder/terraform-eval/lintengine"
+)
+
+func TestParseTerraform_Modules(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{"Modules": [
+		{"Key": "", "Source": "", "Dir": "."},
+		{"Key": "vpc", "Source": "registry.terraform.io/coder/vpc/aws", "Version": "1.2.0", "Dir": ".terraform/modules/vpc"}
+	]}`
+
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		// expect are the loaded modules, by the path of the module call.
+		expect   []string
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "local",
+			files: map[string]string{
+				"main.tf": `
+					module "app" {
+						source = "./modules/app"
+					}`,
+				"modules/app/main.tf": `
+					variable "image" {
+						default = "ubuntu"
+					}
+					module "db" {
+						source = "../db"
+					}`,
+				"modules/db/main.tf": `
+					output "port" {
+						value = 5432
+					}`,
+			},
+			expect: []string{"app", "app.db"},
+		},
+		{
+			name: "local missing",
+			files: map[string]string{
+				"main.tf": `
+					module "app" {
+						source = "./modules/app"
+					}`,
+			},
+			errors: []string{"Module not found"},
+		},
+		{
+			name: "downloaded",
+			files: map[string]string{
+				"main.tf": `
+					module "vpc" {
+						source  = "coder/vpc/aws"
+						version = "1.2.0"
+					}`,
+				".terraform/modules/modules.json": manifest,
+				".terraform/modules/vpc/main.tf": `
+					output "id" {
+						value = "vpc-1"
+					}`,
+			},
+			expect: []string{"vpc"},
+		},
+		{
+			name: "not downloaded",
+			files: map[string]string{
+				"main.tf": `
+					module "vpc" {
+						source = "coder/vpc/aws"
+					}
+					module "git" {
+						source = "git::https://example.com/git.git"
+					}`,
+				".terraform/modules/modules.json": manifest,
+			},
+			warnings: []string{"Module not available offline", "Module not available offline"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			for name, src := range tc.files {
+				err := afero.WriteFile(memfs, name, []byte(src), 0644)
+				require.NoError(t, err)
+			}
+
+			evaluator, _, diags := lintengine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+			require.Equal(t, tc.errors, summaries(diags, hcl.DiagError))
+			require.Equal(t, tc.warnings, summaries(diags, hcl.DiagWarning))
+			if len(tc.errors) > 0 {
+				return
+			}
+
+			var got []string
+			var walk func(config *terraform.Config)
+			walk = func(config *terraform.Config) {
+				for _, child := range config.Children {
+					got = append(got, strings.Join(child.Path, "."))
+					walk(child)
+				}
+			}
+			walk(evaluator.Config)
+			sort.Strings(got)
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
+
+func TestParseTerraform_Content(t *testing.T) {
+	t.Parallel()
+
+	// The module has files with the same names as the root module, only
+	// the blocks of the root module are in the content.
+	files := map[string]string{
+		"main.tf": `
+			module "app" {
+				source = "./modules/app"
+			}
+			resource "null_resource" "root" {}`,
+		"other.tf": `
+			resource "null_resource" "other" {}`,
+		"modules/app/main.tf": `
+			resource "null_resource" "child" {}`,
+		"modules/app/other.tf": `
+			resource "null_resource" "child_other" {}`,
+		"README.md": "# Not HCL",
+	}
+
+	memfs := afero.NewMemMapFs()
+	for name, src := range files {
+		err := afero.WriteFile(memfs, name, []byte(src), 0644)
+		require.NoError(t, err)
+	}
+
+	_, content, diags := lintengine.ParseTerraform(context.Background(), coderism.Input{}, afero.NewIOFS(memfs))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var got []string
+	for _, block := range content.Blocks {
+		got = append(got, block.Type+"."+strings.Join(block.Labels, "."))
+	}
+	sort.Strings(got)
+	require.Equal(t, []string{
+		"module.app",
+		"resource.null_resource.other",
+		"resource.null_resource.root",
+	}, got)
+}
+
+func TestParseHCL(t *testing.T) {
+	t.Parallel()
+
+	memfs := afero.NewMemMapFs()
+	for name, src := range map[string]string{
+		"main.tf":             `resource "null_resource" "root" {}`,
+		"modules/app/main.tf": `resource "null_resource" "child" {}`,
+		"README.md":           "# Not HCL",
+	} {
+		err := afero.WriteFile(memfs, name, []byte(src), 0644)
+		require.NoError(t, err)
+	}
+
+	hp, diags := lintengine.ParseHCL(memfs)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var names []string
+	for name := range hp.Files() {
+		names = append(names, name)
+	}
+	require.Equal(t, []string{"main.tf"}, names)
+}
+
+func TestParseTerraform_ParameterValues(t *testing.T) {
+	t.Parallel()
+
+	// The variable has a different type than the parameter, the parameter
+	// type is the one the input is converted to.
+	const main = `
+		variable "size" {
+			type    = string
+			default = "1"
+		}
+		data "coder_parameter" "size" {
+			name = "size"
+			type = "number"
+		}`
+
+	for _, tc := range []struct {
+		name   string
+		value  string
+		errors []string
+	}{
+		{name: "valid", value: "3"},
+		{name: "invalid", value: "large", errors: []string{"Invalid parameter value"}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			memfs := afero.NewMemMapFs()
+			err := afero.WriteFile(memfs, "main.tf", []byte(main), 0644)
+			require.NoError(t, err)
+
+			input := coderism.Input{
+				ParameterValues: []*proto.RichParameterValue{{Name: "size", Value: tc.value}},
+			}
+			_, _, diags := lintengine.ParseTerraform(context.Background(), input, afero.NewIOFS(memfs))
+			require.Equal(t, tc.errors, summaries(diags, hcl.DiagError))
+			for _, d := range diags {
+				require.NotNil(t, d.Subject)
+				require.Equal(t, 6, d.Subject.Start.Line, "points at the coder_parameter block")
+			}
+		})
+	}
+}
+
+func summaries(diags hcl.Diagnostics, severity hcl.DiagnosticSeverity) []string {
+	var list []string
+	for _, d := range diags {
+		if d.Severity == severity {
+			list = append(list, d.Summary)
+		}
+	}
+	return list
+}